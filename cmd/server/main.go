@@ -4,62 +4,420 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/gorilla/mux"
-
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/cache"
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/config"
+	"github.com/akave-ai/go-akavelink/internal/logging"
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+	"github.com/akave-ai/go-akavelink/internal/s3"
 	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/akave-ai/go-akavelink/internal/tenant"
+	"github.com/akave-ai/go-akavelink/internal/tracing"
 	"github.com/akave-ai/go-akavelink/internal/utils"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
 )
 
+// main initializes the server and routes.
+func main() {
+	utils.LoadEnvConfig()
+
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		config.Usage(os.Stderr)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// Also routes the standard log package, used by dependencies, through
+	// the structured logger.
+	slog.SetDefault(logger)
 
-// server encapsulates dependencies for HTTP handlers.
-type server struct {
-	client *akavesdk.Client
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := run(ctx, cfg); err != nil {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
+	}
 }
 
-// healthHandler responds with a simple status OK message.
-func (s *server) healthHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
-		return
+// run serves until ctx is cancelled and then shuts down gracefully: the
+// server reports not ready, the listeners close, in-flight requests get
+// http.shutdown_timeout to finish before they are cancelled, and the
+// storage clients are closed last.
+func run(ctx context.Context, cfg config.Config) error {
+	shutdownTracing, err := tracing.Setup(ctx)
+	if err != nil {
+		return fmt.Errorf("tracing initialization failed: %w", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
+	masterKeys, err := openMasterKeys(cfg.Encryption)
+	if err != nil {
+		return fmt.Errorf("encryption initialization failed: %w", err)
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("ok"))
+	tenants, err := openTenants(cfg, masterKeys)
+	if err != nil {
+		return fmt.Errorf("tenant registry initialization failed: %w", err)
+	}
+	if tenants != nil {
+		defer tenants.Close()
+	}
+
+	client, err := newStorage(cfg, masterKeys)
+	if err != nil {
+		return fmt.Errorf("client initialization failed: %w", err)
+	}
+	if client != nil {
+		defer client.Close()
+	}
+
+	readiness := server.ReadinessOptions{MinBalance: cfg.Health.MinBalanceWei(), Timeout: cfg.Health.Timeout}
+	if cfg.Storage.Backend == "akave" {
+		probe, err := akavesdk.NewNodeProbe(cfg.Akave.NodeAddress, cfg.Akave.PrivateKey)
+		if err != nil {
+			return fmt.Errorf("node probe initialization failed: %w", err)
+		}
+		defer probe.Close()
+		readiness.Node = probe
+	}
+
+	keys, err := openKeyStore(cfg.Auth.KeysFile)
+	if err != nil {
+		return fmt.Errorf("API key store initialization failed: %w", err)
+	}
+
+	checksums, err := checksum.OpenStore(cfg.Checksums.File)
+	if err != nil {
+		return fmt.Errorf("checksum store initialization failed: %w", err)
+	}
+	defer checksums.Close()
+
+	downloads, err := openCache(cfg.Cache)
+	if err != nil {
+		return fmt.Errorf("download cache initialization failed: %w", err)
+	}
+
+	webhooks, err := openWebhooks(cfg.Webhooks)
+	if err != nil {
+		return fmt.Errorf("webhook initialization failed: %w", err)
+	}
+
+	srv, err := server.New(client, server.Options{
+		TusDir:        cfg.Tus.Dir,
+		TusMaxSize:    cfg.Tus.MaxSize,
+		TusExpiration: cfg.Tus.Expiration,
+		JobsDir:       cfg.Jobs.Dir,
+		JobWorkers:    cfg.Jobs.Workers,
+		JobRetention:  cfg.Jobs.Retention,
+		MaxUploadSize: cfg.HTTP.MaxUploadSize,
+		Metrics:       cfg.Metrics.Enabled && cfg.Metrics.Address == "",
+		Readiness:     readiness,
+		Checksums:     checksums,
+		VerifyOnRead:  cfg.Checksums.VerifyOnRead,
+		Cache:         downloads,
+		Webhooks:      webhooks,
+		Keys:          keys,
+		Tenants:       tenants,
+	})
+	if err != nil {
+		return fmt.Errorf("server initialization failed: %w", err)
+	}
+
+	maintenanceCtx, stopMaintenance := context.WithCancel(context.Background())
+	maintenanceDone := make(chan struct{})
+	go func() {
+		defer close(maintenanceDone)
+		srv.RunMaintenance(maintenanceCtx)
+	}()
+	defer func() {
+		stopMaintenance()
+		<-maintenanceDone
+	}()
+
+	// Request contexts derive from requestCtx so that requests still
+	// running when the drain timeout expires can be cancelled.
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	servers := []*http.Server{newHTTPServer(cfg.Listen, cfg.HTTP, srv.Handler())}
+	names := []string{"server"}
+	if cfg.S3.Address != "" {
+		auth := s3.NewAuthenticator(cfg.S3.Region, s3.Credentials{AccessKeyID: cfg.S3.AccessKeyID, SecretAccessKey: cfg.S3.SecretAccessKey})
+		s3Route := func(*http.Request) string { return "s3" }
+		gateway := s3.New(client, auth, s3.Options{Checksums: checksums, Webhooks: webhooks})
+		gw := logging.RequestIDs(tracing.Middleware(s3Route)(logging.AccessLog(s3Route)(metrics.Instrument(s3Route, gateway))))
+		servers = append(servers, newHTTPServer(cfg.S3.Address, cfg.HTTP, srv.Track(gw)))
+		names = append(names, "S3 gateway")
+	}
+	if cfg.Metrics.Enabled && cfg.Metrics.Address != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", metrics.Handler())
+		servers = append(servers, newHTTPServer(cfg.Metrics.Address, cfg.HTTP, mux))
+		names = append(names, "metrics")
+	}
+
+	serveErr := make(chan error, len(servers))
+	for i, hs := range servers {
+		hs.BaseContext = func(net.Listener) context.Context { return requestCtx }
+		slog.Info(names[i]+" listening", "address", hs.Addr)
+		go func() {
+			if err := hs.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				serveErr <- err
+			}
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down")
+	case err := <-serveErr:
+		cancelRequests()
+		for _, hs := range servers {
+			hs.Close()
+		}
+		srv.Wait(context.Background())
+		return err
+	}
+
+	srv.SetReady(false)
+	if cfg.HTTP.ShutdownDelay > 0 {
+		time.Sleep(cfg.HTTP.ShutdownDelay)
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, hs := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := hs.Shutdown(drainCtx); err != nil {
+				hs.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := srv.Wait(drainCtx); err != nil {
+		slog.Warn("drain timeout expired; cancelling in-flight requests", "requests", srv.InFlight())
+		cancelRequests()
+		srv.Wait(context.Background())
+	}
+	slog.Info("server stopped")
+	return nil
 }
 
+// newHTTPServer returns an http.Server for h with the configured timeouts.
+func newHTTPServer(addr string, cfg config.HTTPConfig, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
 
-// main initializes the server and routes.
-func main() {
-	utils.LoadEnvConfig()
+// newStorage opens the configured storage backend: "akave" talks to an
+// Akave node, "local" keeps everything on this machine under
+// storage.local_dir, or in memory when that is unset. With tenants
+// configured the default Akave wallet is optional and the result may be nil.
+// File contents are encrypted when masterKeys is not nil.
+func newStorage(cfg config.Config, masterKeys akavesdk.KeyWrapper) (akavesdk.Storage, error) {
+	if cfg.Storage.Backend == "local" {
+		if dir := cfg.Storage.LocalDir; dir != "" {
+			slog.Info("using local storage", "dir", dir)
+			st, err := akavesdk.NewDiskStorage(dir)
+			if err != nil {
+				return nil, err
+			}
+			return encrypt(st, masterKeys), nil
+		}
+		slog.Warn("using in-memory storage; data is lost on exit")
+		return encrypt(akavesdk.NewMemoryStorage(), masterKeys), nil
+	}
+
+	if cfg.Akave.PrivateKey == "" {
+		slog.Warn("AKAVE_PRIVATE_KEY is not set; only API keys assigned to a tenant can be used")
+		return nil, nil
+	}
+	client, err := akavesdk.NewClient(akaveConfig(cfg.Akave, cfg.Akave.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	return encrypt(client, masterKeys), nil
+}
+
+// encrypt wraps st in client-side encryption when masterKeys is not nil.
+func encrypt(st akavesdk.Storage, masterKeys akavesdk.KeyWrapper) akavesdk.Storage {
+	if masterKeys == nil {
+		return st
+	}
+	return akavesdk.NewEncryptedStorage(st, masterKeys)
+}
 
-	key := os.Getenv("AKAVE_PRIVATE_KEY")
-	node := os.Getenv("AKAVE_NODE_ADDRESS")
-	if key == "" || node == "" {
-		log.Fatal("AKAVE_PRIVATE_KEY and AKAVE_NODE_ADDRESS are required")
+// openMasterKeys returns the master keys configured under encryption, or
+// nil when client-side encryption is disabled.
+func openMasterKeys(cfg config.EncryptionConfig) (akavesdk.KeyWrapper, error) {
+	switch {
+	case cfg.KeyFile != "":
+		keys, err := akavesdk.LoadKeyFile(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("encrypting file contents", "key_file", cfg.KeyFile)
+		return keys, nil
+	case cfg.KeyCommand != "":
+		slog.Info("encrypting file contents", "key_command", cfg.KeyCommand)
+		return akavesdk.KeyCommand{Path: cfg.KeyCommand}, nil
 	}
+	return nil, nil
+}
 
-	cfg := akavesdk.Config{
-		NodeAddress:       node,
-		MaxConcurrency:    10,
-		BlockPartSize:     1 << 20,
-		UseConnectionPool: true,
+// akaveConfig returns the client configuration for the wallet key.
+func akaveConfig(cfg config.AkaveConfig, key string) akavesdk.Config {
+	return akavesdk.Config{
+		NodeAddress:       cfg.NodeAddress,
+		MaxConcurrency:    cfg.MaxConcurrency,
+		BlockPartSize:     cfg.BlockPartSize,
+		UseConnectionPool: cfg.UseConnectionPool,
 		PrivateKeyHex:     key,
+		Retry: akavesdk.RetryPolicy{
+			MaxAttempts:    cfg.Retry.MaxAttempts,
+			InitialBackoff: cfg.Retry.InitialBackoff,
+			MaxBackoff:     cfg.Retry.MaxBackoff,
+		},
+	}
+}
+
+// openTenants loads the tenant registry named by tenants.file. Tenant
+// clients are closed after tenants.idle_timeout without use.
+func openTenants(cfg config.Config, masterKeys akavesdk.KeyWrapper) (*tenant.Pool, error) {
+	if cfg.Tenants.File == "" {
+		return nil, nil
 	}
-	client, err := akavesdk.NewClient(cfg)
+	registry, err := tenant.LoadRegistry(cfg.Tenants.File)
 	if err != nil {
-		log.Fatalf("client initialization failed: %v", err)
+		return nil, err
+	}
+	slog.Info("serving tenants", "count", len(registry.IDs()))
+	pool := tenant.NewPool(registry, func(t tenant.Tenant) (akavesdk.Storage, error) {
+		client, err := akavesdk.NewClient(akaveConfig(cfg.Akave, t.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open client for tenant %q: %w", t.ID, err)
+		}
+		return encrypt(client, masterKeys), nil
+	}, cfg.Tenants.IdleTimeout)
+	if err := metrics.RegisterTenantPool(pool.Len); err != nil {
+		return nil, err
 	}
-	defer client.Close()
+	return pool, nil
+}
 
-	r := mux.NewRouter()
-	srv := &server{client: client}
+// openCache returns the download cache, or nil when it is disabled.
+func openCache(cfg config.CacheConfig) (*cache.Cache, error) {
+	if cfg.Dir == "" {
+		return nil, nil
+	}
+	return cache.New(cache.Config{Dir: cfg.Dir, MaxSize: cfg.MaxSize, MaxFileSize: cfg.MaxFileSize, TTL: cfg.TTL})
+}
 
-	r.HandleFunc("/health", srv.healthHandler).Methods("GET")
+// openWebhooks returns the webhook dispatcher, or nil when webhooks are
+// disabled.
+func openWebhooks(cfg config.WebhooksConfig) (*webhook.Dispatcher, error) {
+	if cfg.File == "" {
+		return nil, nil
+	}
+	subs, err := webhook.OpenStore(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	return webhook.NewDispatcher(webhook.Config{
+		Subscriptions:  subs,
+		DeadLetterPath: cfg.DeadLetterFile,
+		MaxAttempts:    cfg.MaxAttempts,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+		Timeout:        cfg.Timeout,
+	}), nil
+}
 
-	log.Println("Server listening on :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+// openKeyStore loads the API keys from path. When the file holds no keys
+// yet an admin key is minted, so the operator can create further keys
+// through the admin API. Its token is written to path+".admin-token",
+// readable by the owner only, and never to the log.
+func openKeyStore(path string) (*auth.Store, error) {
+	if path == "" {
+		slog.Warn("auth.keys_file is not set; the API is open to anyone who can reach it")
+		return nil, nil
+	}
+	keys, err := auth.OpenStore(path)
+	if err != nil {
+		return nil, err
+	}
+	if keys.Len() == 0 {
+		key, token, err := keys.Create(auth.Key{Name: "bootstrap-admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+		if err != nil {
+			return nil, err
+		}
+		tokenFile := path + ".admin-token"
+		if err := writeSecretFile(tokenFile, token+"\n"); err != nil {
+			if rerr := keys.Revoke(key.ID); rerr != nil {
+				slog.Error("failed to revoke bootstrap admin API key", "key_id", key.ID, "error", rerr)
+			}
+			return nil, fmt.Errorf("failed to save bootstrap admin API key: %w", err)
+		}
+		slog.Warn("created bootstrap admin API key; read its token from the file and then delete the file",
+			"key_id", key.ID, "file", tokenFile)
+	}
+	return keys, nil
+}
+
+// writeSecretFile writes data to path with permissions that let only the
+// owner read it, tightening them if the file already exists.
+func writeSecretFile(path, data string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteString(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
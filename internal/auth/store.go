@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenPrefix marks strings that are API keys, which helps secret scanners.
+const tokenPrefix = "akl"
+
+// storedKey is a Key as persisted, with the digest of its secret.
+type storedKey struct {
+	Key
+	Hash string `json:"hash"`
+}
+
+// Store keeps API keys in a JSON file. All keys are held in memory; every
+// change rewrites the file atomically.
+type Store struct {
+	path string
+	now  func() time.Time
+
+	mu   sync.RWMutex
+	keys map[string]storedKey
+}
+
+// OpenStore loads the keys in path, creating an empty store when the file
+// does not exist yet.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, now: time.Now, keys: make(map[string]storedKey)}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API key file: %w", err)
+	}
+	var keys []storedKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse API key file: %w", err)
+	}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s, nil
+}
+
+// Len returns the number of keys in the store.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// Create mints a new key with the name, scopes, buckets and tenant of spec
+// and returns it together with its token. The token is not stored and
+// cannot be recovered later.
+func (s *Store) Create(spec Key) (Key, string, error) {
+	if len(spec.Scopes) == 0 {
+		return Key{}, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, sc := range spec.Scopes {
+		if _, err := ParseScope(string(sc)); err != nil {
+			return Key{}, "", err
+		}
+	}
+
+	id, err := randomString(8, hex.EncodeToString)
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	k := Key{
+		ID:        id,
+		Name:      spec.Name,
+		Scopes:    spec.Scopes,
+		Buckets:   spec.Buckets,
+		Tenant:    spec.Tenant,
+		CreatedAt: s.now().UTC().Truncate(time.Second),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[id] = storedKey{Key: k, Hash: hashSecret(secret)}
+	if err := s.persist(); err != nil {
+		delete(s.keys, id)
+		return Key{}, "", err
+	}
+	return k, tokenPrefix + "_" + id + "_" + secret, nil
+}
+
+// List returns every key ordered by creation time.
+func (s *Store) List() []Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		out = append(out, k.Key)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Get returns the key with the given ID.
+func (s *Store) Get(id string) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return Key{}, fmt.Errorf("key %q: %w", id, ErrKeyNotFound)
+	}
+	return k.Key, nil
+}
+
+// Revoke deletes the key with the given ID.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return fmt.Errorf("failed to revoke key %q: %w", id, ErrKeyNotFound)
+	}
+	delete(s.keys, id)
+	if err := s.persist(); err != nil {
+		s.keys[id] = k
+		return err
+	}
+	return nil
+}
+
+// Authenticate returns the key a token belongs to.
+func (s *Store) Authenticate(token string) (Key, error) {
+	prefix, rest, _ := strings.Cut(token, "_")
+	id, secret, ok := strings.Cut(rest, "_")
+	if prefix != tokenPrefix || !ok {
+		return Key{}, ErrInvalidKey
+	}
+
+	s.mu.RLock()
+	k, found := s.keys[id]
+	s.mu.RUnlock()
+	if !found || subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hashSecret(secret))) != 1 {
+		return Key{}, ErrInvalidKey
+	}
+	return k.Key, nil
+}
+
+// persist writes all keys to disk. The caller must hold s.mu for writing.
+func (s *Store) persist() error {
+	keys := make([]storedKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	b, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to persist API keys: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("failed to persist API keys: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
@@ -0,0 +1,307 @@
+// Package cache keeps recently downloaded files on local disk so that hot
+// files are served without fetching them from storage again.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+)
+
+// Header reports whether a download was served from the cache.
+const Header = "X-Cache"
+
+// Result is the outcome of a cache lookup, reported in Header.
+type Result string
+
+// Lookup results.
+const (
+	// Hit is served from a cached copy.
+	Hit Result = "HIT"
+	// Miss was fetched from storage and cached.
+	Miss Result = "MISS"
+	// Bypass is streamed from storage without being cached: the file is
+	// too large, or only part of an uncached file was requested.
+	Bypass Result = "BYPASS"
+)
+
+// blobExt and tmpExt name the files the cache owns in its directory.
+const (
+	blobExt = ".blob"
+	tmpExt  = ".tmp"
+)
+
+// Key identifies a cached file. The root CID is part of the key, so a file
+// replaced under the same name is never served from an older copy.
+type Key struct {
+	// Tenant owns the bucket; empty for the server's default wallet.
+	Tenant  string
+	Bucket  string
+	File    string
+	RootCID string
+}
+
+func (k Key) id() string {
+	sum := sha256.Sum256([]byte(k.Tenant + "\x00" + k.Bucket + "\x00" + k.File + "\x00" + k.RootCID))
+	return hex.EncodeToString(sum[:])
+}
+
+// Config configures a Cache.
+type Config struct {
+	// Dir holds the cached files. Files left by a previous run are removed.
+	Dir string
+	// MaxSize caps the total size of the cached files in bytes. The least
+	// recently used files are evicted to make room.
+	MaxSize int64
+	// MaxFileSize is the largest file that is cached; larger files bypass
+	// the cache. Zero means MaxSize.
+	MaxFileSize int64
+	// TTL is how long a file stays cached after it was fetched; zero means
+	// until it is evicted.
+	TTL time.Duration
+}
+
+// Cache is an LRU read-through cache of file contents on local disk.
+// Concurrent misses of the same file are fetched from storage once.
+type Cache struct {
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // of *entry, most recently used first
+	entries map[string]*list.Element
+	size    int64
+	flights map[string]*flight
+}
+
+type entry struct {
+	key     Key
+	path    string
+	size    int64
+	fetched time.Time
+}
+
+// flight is a fetch in progress that other misses of the same key wait for.
+type flight struct {
+	done chan struct{}
+	err  error
+}
+
+// New returns an empty cache in cfg.Dir, removing the files a previous run
+// left there.
+func New(cfg Config) (*Cache, error) {
+	if cfg.MaxFileSize <= 0 || cfg.MaxFileSize > cfg.MaxSize {
+		cfg.MaxFileSize = cfg.MaxSize
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	stale, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	for _, f := range stale {
+		if name := f.Name(); strings.HasSuffix(name, blobExt) || strings.HasSuffix(name, tmpExt) {
+			os.Remove(filepath.Join(cfg.Dir, name))
+		}
+	}
+	metrics.SetCacheSize(0)
+	return &Cache{
+		cfg:     cfg,
+		now:     time.Now,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		flights: make(map[string]*flight),
+	}, nil
+}
+
+// Get returns an open copy of the file identified by key, which is size
+// bytes long. On a miss fill writes the content, fetched from storage, and
+// the copy is cached; concurrent misses of one key share a single fill,
+// which runs on after the requests waiting for it are cancelled. Files
+// larger than the configured maximum are not cached: Get returns a nil
+// file and Bypass, and the caller streams the file from storage itself.
+// The caller closes the returned file.
+func (c *Cache) Get(ctx context.Context, key Key, size int64, fill func(ctx context.Context, w io.Writer) error) (*os.File, Result, error) {
+	if size > c.cfg.MaxFileSize {
+		metrics.CacheLookup(string(Bypass))
+		return nil, Bypass, nil
+	}
+	id := key.id()
+	result := Hit
+	for {
+		c.mu.Lock()
+		if f := c.open(id); f != nil {
+			c.mu.Unlock()
+			metrics.CacheLookup(string(result))
+			return f, result, nil
+		}
+		result = Miss
+		fl, ok := c.flights[id]
+		if !ok {
+			fl = &flight{done: make(chan struct{})}
+			c.flights[id] = fl
+			go c.fetch(context.WithoutCancel(ctx), id, key, size, fl, fill)
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-fl.done:
+		case <-ctx.Done():
+			return nil, Miss, ctx.Err()
+		}
+		if fl.err != nil {
+			return nil, Miss, fl.err
+		}
+	}
+}
+
+// Lookup returns an open copy of the file identified by key when it is
+// cached, and otherwise a nil file and Bypass without fetching anything.
+// It serves requests for parts of a file, which should not wait for the
+// whole file to be fetched. The caller closes the returned file.
+func (c *Cache) Lookup(key Key) (*os.File, Result) {
+	c.mu.Lock()
+	f := c.open(key.id())
+	c.mu.Unlock()
+	result := Hit
+	if f == nil {
+		result = Bypass
+	}
+	metrics.CacheLookup(string(result))
+	return f, result
+}
+
+// open returns the cached file of id, or nil on a miss. Expired entries
+// are removed. The caller must hold c.mu.
+func (c *Cache) open(id string) *os.File {
+	el, ok := c.entries[id]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*entry)
+	if c.cfg.TTL > 0 && c.now().Sub(e.fetched) > c.cfg.TTL {
+		c.remove(el)
+		return nil
+	}
+	f, err := os.Open(e.path)
+	if err != nil {
+		c.remove(el)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return f
+}
+
+// fetch fills a new cache file for key and completes fl.
+func (c *Cache) fetch(ctx context.Context, id string, key Key, size int64, fl *flight, fill func(ctx context.Context, w io.Writer) error) {
+	path := filepath.Join(c.cfg.Dir, id+blobExt)
+	err := c.write(ctx, path, size, fill)
+
+	c.mu.Lock()
+	if err == nil {
+		if el, ok := c.entries[id]; ok {
+			c.remove(el)
+		}
+		c.entries[id] = c.lru.PushFront(&entry{key: key, path: path, size: size, fetched: c.now()})
+		c.size += size
+		c.evict()
+	}
+	delete(c.flights, id)
+	fl.err = err
+	close(fl.done)
+	c.mu.Unlock()
+}
+
+// write stores the content written by fill in path.
+func (c *Cache) write(ctx context.Context, path string, size int64, fill func(ctx context.Context, w io.Writer) error) error {
+	f, err := os.CreateTemp(c.cfg.Dir, "*"+tmpExt)
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	cw := &countingWriter{w: f}
+	err = fill(ctx, cw)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to write cache file: %w", cerr)
+	}
+	if err != nil {
+		return err
+	}
+	if cw.n != size {
+		return fmt.Errorf("fetched %d bytes instead of %d", cw.n, size)
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// evict removes the least recently used entries until the cache fits its
+// maximum size. The caller must hold c.mu.
+func (c *Cache) evict() {
+	for c.size > c.cfg.MaxSize {
+		c.remove(c.lru.Back())
+	}
+	metrics.SetCacheSize(c.size)
+}
+
+// remove deletes an entry and its file. Open copies stay readable until
+// they are closed. The caller must hold c.mu.
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.entries, e.key.id())
+	c.size -= e.size
+	os.Remove(e.path)
+	metrics.SetCacheSize(c.size)
+}
+
+// Invalidate removes every cached copy of a tenant's file, whatever its
+// root CID.
+func (c *Cache) Invalidate(tenant, bucket, file string) {
+	c.removeIf(func(k Key) bool { return k.Tenant == tenant && k.Bucket == bucket && k.File == file })
+}
+
+// InvalidateBucket removes the cached copies of every file in a tenant's
+// bucket.
+func (c *Cache) InvalidateBucket(tenant, bucket string) {
+	c.removeIf(func(k Key) bool { return k.Tenant == tenant && k.Bucket == bucket })
+}
+
+func (c *Cache) removeIf(match func(Key) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.lru.Front(); el != nil; {
+		next := el.Next()
+		if match(el.Value.(*entry).key) {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+// Size returns the total size of the cached files.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
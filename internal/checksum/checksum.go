@@ -0,0 +1,212 @@
+// Package checksum computes file digests while uploads and downloads
+// stream, verifies them against the digests declared by clients, and
+// remembers the digests of stored files.
+package checksum
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+)
+
+// Headers carrying digests. Content-MD5 is the base64 encoded MD5 of the
+// body (RFC 1864). X-Checksum-SHA256 is hex or base64 encoded, and
+// X-Checksum-CRC32C is the base64 encoded big-endian CRC32C, as used by
+// Google Cloud Storage and Amazon S3. Responses use hex for SHA-256.
+const (
+	MD5Header    = "Content-MD5"
+	SHA256Header = "X-Checksum-SHA256"
+	CRC32CHeader = "X-Checksum-CRC32C"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Digests are the digests of a file's content.
+type Digests struct {
+	// SHA256 is hex encoded.
+	SHA256 string `json:"sha256"`
+	// CRC32C is the base64 encoded big-endian checksum.
+	CRC32C string `json:"crc32c"`
+}
+
+// Expected holds the digests a client declared for an upload. Nil fields
+// are not checked.
+type Expected struct {
+	MD5    []byte
+	SHA256 []byte
+	CRC32C []byte
+}
+
+// ParseExpected reads the declared digests from the request headers.
+func ParseExpected(h http.Header) (Expected, error) {
+	var want Expected
+	var err error
+	if v := h.Get(MD5Header); v != "" {
+		if want.MD5, err = decode(v, md5.Size, false); err != nil {
+			return Expected{}, fmt.Errorf("invalid %s header: %w", MD5Header, err)
+		}
+	}
+	if v := h.Get(SHA256Header); v != "" {
+		if want.SHA256, err = decode(v, sha256.Size, true); err != nil {
+			return Expected{}, fmt.Errorf("invalid %s header: %w", SHA256Header, err)
+		}
+	}
+	if v := h.Get(CRC32CHeader); v != "" {
+		if want.CRC32C, err = decode(v, crc32.Size, true); err != nil {
+			return Expected{}, fmt.Errorf("invalid %s header: %w", CRC32CHeader, err)
+		}
+	}
+	return want, nil
+}
+
+// decode decodes a base64, or optionally hex, encoded digest of size bytes.
+func decode(s string, size int, allowHex bool) ([]byte, error) {
+	if allowHex && len(s) == 2*size {
+		if b, err := hex.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != size {
+		return nil, fmt.Errorf("expected a %d-byte digest", size)
+	}
+	return b, nil
+}
+
+// MismatchError reports content that does not match a declared digest.
+type MismatchError struct {
+	Algorithm string
+	Expected  string
+	Actual    string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s, computed %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// Reader computes the digests of everything read through it. When the
+// underlying reader is exhausted it checks them against the expected
+// digests and fails with a *MismatchError instead of io.EOF if one
+// differs, so a consumer that commits on EOF never commits corrupt data.
+type Reader struct {
+	r      io.Reader
+	want   Expected
+	sha    hash.Hash
+	crc    hash.Hash32
+	md5    hash.Hash
+	err    *MismatchError
+	closed bool
+}
+
+// NewReader returns a Reader of r checking the digests in want.
+func NewReader(r io.Reader, want Expected) *Reader {
+	cr := &Reader{r: r, want: want, sha: sha256.New(), crc: crc32.New(castagnoli)}
+	if want.MD5 != nil {
+		cr.md5 = md5.New()
+	}
+	return cr
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.r.Read(p)
+	r.sha.Write(p[:n])
+	r.crc.Write(p[:n])
+	if r.md5 != nil {
+		r.md5.Write(p[:n])
+	}
+	if err == io.EOF && !r.closed {
+		r.closed = true
+		if r.err = r.verify(); r.err != nil {
+			return n, r.err
+		}
+	}
+	return n, err
+}
+
+func (r *Reader) verify() *MismatchError {
+	checks := []struct {
+		name string
+		want []byte
+		got  hash.Hash
+		enc  func([]byte) string
+	}{
+		{"md5", r.want.MD5, r.md5, base64.StdEncoding.EncodeToString},
+		{"sha256", r.want.SHA256, r.sha, hex.EncodeToString},
+		{"crc32c", r.want.CRC32C, r.crc, base64.StdEncoding.EncodeToString},
+	}
+	for _, c := range checks {
+		if c.want == nil {
+			continue
+		}
+		if got := c.got.Sum(nil); !bytes.Equal(got, c.want) {
+			return &MismatchError{Algorithm: c.name, Expected: c.enc(c.want), Actual: c.enc(got)}
+		}
+	}
+	return nil
+}
+
+// Mismatch returns the mismatch detected at the end of the content, or nil.
+func (r *Reader) Mismatch() *MismatchError {
+	return r.err
+}
+
+// Digests returns the digests of the content read so far.
+func (r *Reader) Digests() Digests {
+	crc := make([]byte, crc32.Size)
+	binary.BigEndian.PutUint32(crc, r.crc.Sum32())
+	return Digests{SHA256: hex.EncodeToString(r.sha.Sum(nil)), CRC32C: base64.StdEncoding.EncodeToString(crc)}
+}
+
+// Writer forwards everything written to it except the last byte, which
+// Close releases only once the content matched the expected SHA-256. A
+// client receiving a corrupt file therefore never receives it complete,
+// and a declared Content-Length makes the truncation visible.
+type Writer struct {
+	w    io.Writer
+	want string
+	sha  hash.Hash
+	held []byte
+}
+
+// NewWriter returns a Writer to w expecting the hex encoded SHA-256 want.
+func NewWriter(w io.Writer, want string) *Writer {
+	return &Writer{w: w, want: want, sha: sha256.New(), held: make([]byte, 0, 1)}
+}
+
+func (v *Writer) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	v.sha.Write(p)
+	if len(v.held) > 0 {
+		if _, err := v.w.Write(v.held); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := v.w.Write(p[:len(p)-1]); err != nil {
+		return 0, err
+	}
+	v.held = append(v.held[:0], p[len(p)-1])
+	return len(p), nil
+}
+
+// Close checks the digest of the content and writes the held back byte if
+// it matches.
+func (v *Writer) Close() error {
+	if got := hex.EncodeToString(v.sha.Sum(nil)); got != v.want {
+		return &MismatchError{Algorithm: "sha256", Expected: v.want, Actual: got}
+	}
+	_, err := v.w.Write(v.held)
+	return err
+}
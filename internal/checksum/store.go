@@ -0,0 +1,235 @@
+package checksum
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// compactMinRecords is the log size below which a Store is never
+// compacted.
+const compactMinRecords = 1024
+
+// FileRef identifies a stored file.
+type FileRef struct {
+	// Tenant owns the bucket; empty for the server's default wallet.
+	Tenant string `json:"tenant,omitempty"`
+	Bucket string `json:"bucket"`
+	Name   string `json:"file"`
+}
+
+// entry is the recorded state of one file.
+type entry struct {
+	RootCID string
+	Digests
+}
+
+// record is one line of the log: the digests of a file, or its removal.
+type record struct {
+	FileRef
+	RootCID string `json:"rootCid,omitempty"`
+	Digests
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Store remembers the digests of stored files. An entry is only returned
+// for the root CID it was recorded with, so an entry left behind by a file
+// replaced out of band is never reported for the new content.
+//
+// With a path the entries are kept in an append-only log of JSON lines:
+// recording or removing a file appends one line. The log is compacted when
+// it is opened and whenever it has grown to more than twice the number of
+// live entries. Without a path the entries are held in memory only.
+type Store struct {
+	path string
+
+	mu      sync.RWMutex
+	entries map[FileRef]entry
+	log     *os.File
+	records int
+}
+
+// OpenStore replays the log in path, creating it when it does not exist
+// yet. An empty path keeps the store in memory.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, entries: make(map[FileRef]entry)}
+	if path == "" {
+		return s, nil
+	}
+
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read checksum log: %w", err)
+	default:
+		err = s.replay(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// replay applies every record of the log in r. A truncated last line, left
+// by a crash during an append, is ignored and dropped by the compaction
+// that follows.
+func (s *Store) replay(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		b, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read checksum log: %w", err)
+		}
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return fmt.Errorf("failed to parse checksum log line %d: %w", line, err)
+		}
+		s.apply(rec)
+	}
+}
+
+// apply updates the entries with rec. The caller must hold s.mu for
+// writing, or own s exclusively.
+func (s *Store) apply(rec record) {
+	if rec.Deleted {
+		delete(s.entries, rec.FileRef)
+	} else {
+		s.entries[rec.FileRef] = entry{RootCID: rec.RootCID, Digests: rec.Digests}
+	}
+}
+
+// Get returns the digests recorded for ref if they were recorded for the
+// content with rootCID.
+func (s *Store) Get(ref FileRef, rootCID string) (Digests, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[ref]
+	if !ok || e.RootCID != rootCID {
+		return Digests{}, false
+	}
+	return e.Digests, true
+}
+
+// Put records the digests of the file ref stored with rootCID.
+func (s *Store) Put(ref FileRef, rootCID string, d Digests) error {
+	if rootCID == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[ref]; ok && e.RootCID == rootCID && e.Digests == d {
+		return nil
+	}
+	return s.append(record{FileRef: ref, RootCID: rootCID, Digests: d})
+}
+
+// Delete forgets the digests of the file ref.
+func (s *Store) Delete(ref FileRef) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[ref]; !ok {
+		return nil
+	}
+	return s.append(record{FileRef: ref, Deleted: true})
+}
+
+// Close closes the log.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return nil
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+// append applies rec and writes it to the log, compacting the log once
+// most of its records are dead. The caller must hold s.mu for writing.
+func (s *Store) append(rec record) error {
+	s.apply(rec)
+	if s.path == "" {
+		return nil
+	}
+	if s.log == nil {
+		return errors.New("checksum log is closed")
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to persist checksums: %w", err)
+	}
+	s.records++
+
+	if s.records > compactMinRecords && s.records > 2*len(s.entries) {
+		return s.compact()
+	}
+	return nil
+}
+
+// compact rewrites the log with one record per live entry and reopens it
+// for appending. The caller must hold s.mu for writing.
+func (s *Store) compact() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to persist checksums: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to persist checksums: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for ref, e := range s.entries {
+		if err := enc.Encode(record{FileRef: ref, RootCID: e.RootCID, Digests: e.Digests}); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to persist checksums: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to persist checksums: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to persist checksums: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to persist checksums: %w", err)
+	}
+
+	if s.log != nil {
+		s.log.Close()
+	}
+	s.log, err = os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		s.log = nil
+		return fmt.Errorf("failed to open checksum log: %w", err)
+	}
+	s.records = len(s.entries)
+	return nil
+}
@@ -0,0 +1,387 @@
+// Package config defines the typed server configuration and loads it from
+// defaults, a YAML or TOML file, environment variables and command-line
+// flags, each source overriding the ones before it.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"strings"
+	"time"
+)
+
+// Config is the complete server configuration.
+//
+// Every leaf field carries a `config` tag naming its key in configuration
+// files, an `env` tag naming its environment variable and a `usage` tag
+// describing it. Its command-line flag is the dotted key path with
+// underscores replaced by dashes, e.g. -akave.node-address.
+type Config struct {
+	// Listen is the address of the REST API.
+	Listen string `config:"listen" env:"AKAVE_LISTEN_ADDRESS" usage:"address of the REST API"`
+
+	Akave   AkaveConfig   `config:"akave"`
+	Storage StorageConfig `config:"storage"`
+	HTTP    HTTPConfig    `config:"http"`
+	Auth    AuthConfig    `config:"auth"`
+	Tenants TenantsConfig `config:"tenants"`
+	Tus     TusConfig     `config:"tus"`
+	Jobs    JobsConfig    `config:"jobs"`
+	S3      S3Config      `config:"s3"`
+	Metrics MetricsConfig `config:"metrics"`
+	Log     LogConfig     `config:"log"`
+	Health  HealthConfig  `config:"health"`
+
+	Encryption EncryptionConfig `config:"encryption"`
+	Checksums  ChecksumsConfig  `config:"checksums"`
+	Cache      CacheConfig      `config:"cache"`
+	Webhooks   WebhooksConfig   `config:"webhooks"`
+}
+
+// AkaveConfig holds the connection and tuning settings of the Akave SDK.
+type AkaveConfig struct {
+	NodeAddress       string `config:"node_address" env:"AKAVE_NODE_ADDRESS" usage:"Akave node gRPC address"`
+	PrivateKey        string `config:"private_key" env:"AKAVE_PRIVATE_KEY" usage:"hex-encoded wallet private key"`
+	MaxConcurrency    int    `config:"max_concurrency" env:"AKAVE_MAX_CONCURRENCY" usage:"parallel block transfers per file"`
+	BlockPartSize     int64  `config:"block_part_size" env:"AKAVE_BLOCK_PART_SIZE" usage:"block part size in bytes"`
+	UseConnectionPool bool   `config:"use_connection_pool" env:"AKAVE_USE_CONNECTION_POOL" usage:"reuse gRPC connections to storage nodes"`
+
+	Retry RetryConfig `config:"retry"`
+}
+
+// RetryConfig controls retries of Akave calls that fail with a transient
+// error, such as an unavailable node or a transaction nonce conflict.
+type RetryConfig struct {
+	// MaxAttempts counts the first attempt; 1 disables retries.
+	MaxAttempts    int           `config:"max_attempts" env:"AKAVE_RETRY_MAX_ATTEMPTS" usage:"attempts per Akave call, including the first"`
+	InitialBackoff time.Duration `config:"initial_backoff" env:"AKAVE_RETRY_INITIAL_BACKOFF" usage:"delay before the first retry; doubles with every retry"`
+	MaxBackoff     time.Duration `config:"max_backoff" env:"AKAVE_RETRY_MAX_BACKOFF" usage:"maximum delay between retries"`
+}
+
+// StorageConfig selects the storage backend.
+type StorageConfig struct {
+	// Backend is "akave" or "local".
+	Backend string `config:"backend" env:"AKAVE_STORAGE" usage:"storage backend: akave or local"`
+	// LocalDir persists the local backend; empty keeps it in memory.
+	LocalDir string `config:"local_dir" env:"AKAVE_LOCAL_STORAGE_DIR" usage:"directory of the local backend (in memory when empty)"`
+}
+
+// HTTPConfig holds timeouts and limits of the HTTP listeners.
+type HTTPConfig struct {
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout" env:"AKAVE_HTTP_READ_HEADER_TIMEOUT" usage:"time allowed to read request headers"`
+	ReadTimeout       time.Duration `config:"read_timeout" env:"AKAVE_HTTP_READ_TIMEOUT" usage:"time allowed to read a whole request (0 = unlimited)"`
+	WriteTimeout      time.Duration `config:"write_timeout" env:"AKAVE_HTTP_WRITE_TIMEOUT" usage:"time allowed to write a response (0 = unlimited)"`
+	IdleTimeout       time.Duration `config:"idle_timeout" env:"AKAVE_HTTP_IDLE_TIMEOUT" usage:"keep-alive idle timeout"`
+	// ShutdownDelay is how long the server keeps serving after reporting
+	// not ready, giving load balancers time to stop routing to it.
+	ShutdownDelay time.Duration `config:"shutdown_delay" env:"AKAVE_SHUTDOWN_DELAY" usage:"time between reporting not ready and closing the listeners"`
+	// ShutdownTimeout bounds how long in-flight requests may drain before
+	// they are cancelled.
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" env:"AKAVE_SHUTDOWN_TIMEOUT" usage:"time allowed for in-flight requests to finish on shutdown"`
+	// MaxUploadSize caps the body of single-request uploads; zero means
+	// unlimited.
+	MaxUploadSize int64 `config:"max_upload_size" env:"AKAVE_MAX_UPLOAD_SIZE" usage:"maximum upload size in bytes (0 = unlimited)"`
+}
+
+// AuthConfig enables API key authentication.
+type AuthConfig struct {
+	KeysFile string `config:"keys_file" env:"AKAVE_API_KEYS_FILE" usage:"API key file; enables authentication"`
+}
+
+// TenantsConfig enables per-tenant wallets.
+type TenantsConfig struct {
+	File        string        `config:"file" env:"AKAVE_TENANTS_FILE" usage:"tenant registry file; enables tenants"`
+	IdleTimeout time.Duration `config:"idle_timeout" env:"AKAVE_TENANT_IDLE_TIMEOUT" usage:"close tenant clients unused for this long"`
+}
+
+// TusConfig enables resumable uploads.
+type TusConfig struct {
+	Dir        string        `config:"dir" env:"AKAVE_TUS_DIR" usage:"staging directory; enables tus resumable uploads"`
+	MaxSize    int64         `config:"max_size" env:"AKAVE_TUS_MAX_SIZE" usage:"maximum resumable upload size in bytes (0 = unlimited)"`
+	Expiration time.Duration `config:"expiration" env:"AKAVE_TUS_EXPIRATION" usage:"how long an idle resumable upload is kept"`
+}
+
+// JobsConfig enables background uploads requested with
+// "Prefer: respond-async".
+type JobsConfig struct {
+	Dir       string        `config:"dir" env:"AKAVE_JOBS_DIR" usage:"spool directory; enables background uploads"`
+	Workers   int           `config:"workers" env:"AKAVE_JOBS_WORKERS" usage:"background uploads run at once"`
+	Retention time.Duration `config:"retention" env:"AKAVE_JOBS_RETENTION" usage:"how long finished background uploads can be queried"`
+}
+
+// S3Config enables the S3-compatible gateway. The gateway is single-tenant:
+// it always uses the default wallet.
+type S3Config struct {
+	Address         string `config:"address" env:"AKAVE_S3_ADDRESS" usage:"address of the S3 gateway; enables it"`
+	AccessKeyID     string `config:"access_key_id" env:"AKAVE_S3_ACCESS_KEY_ID" usage:"S3 access key ID"`
+	SecretAccessKey string `config:"secret_access_key" env:"AKAVE_S3_SECRET_ACCESS_KEY" usage:"S3 secret access key"`
+	Region          string `config:"region" env:"AKAVE_S3_REGION" usage:"S3 region reported to clients"`
+}
+
+// MetricsConfig controls the Prometheus metrics endpoint.
+type MetricsConfig struct {
+	Enabled bool `config:"enabled" env:"AKAVE_METRICS_ENABLED" usage:"serve Prometheus metrics under /metrics"`
+	// Address serves /metrics on a separate listener instead of the REST
+	// API, keeping it off the public port.
+	Address string `config:"address" env:"AKAVE_METRICS_ADDRESS" usage:"separate address for /metrics (default: the REST API listener)"`
+}
+
+// LogConfig controls the structured logs written to standard error.
+type LogConfig struct {
+	Level  string `config:"level" env:"AKAVE_LOG_LEVEL" usage:"minimum log level: debug, info, warn or error"`
+	Format string `config:"format" env:"AKAVE_LOG_FORMAT" usage:"log format: json or text"`
+}
+
+// HealthConfig controls the readiness checks of /readyz.
+type HealthConfig struct {
+	Timeout time.Duration `config:"timeout" env:"AKAVE_READINESS_TIMEOUT" usage:"time allowed for each readiness check"`
+	// MinBalance is a decimal amount in wei; empty disables the check.
+	MinBalance string `config:"min_balance" env:"AKAVE_MIN_WALLET_BALANCE" usage:"report not ready while the wallet balance is below this many wei"`
+}
+
+// MinBalanceWei returns the parsed minimum wallet balance, or nil when the
+// check is disabled.
+func (h HealthConfig) MinBalanceWei() *big.Int {
+	if h.MinBalance == "" {
+		return nil
+	}
+	n, ok := new(big.Int).SetString(h.MinBalance, 10)
+	if !ok || n.Sign() < 0 {
+		return nil
+	}
+	return n
+}
+
+// EncryptionConfig enables client-side encryption of file contents. The
+// master key comes from a key file or from an external key command, such
+// as a bridge to a KMS.
+type EncryptionConfig struct {
+	KeyFile    string `config:"key_file" env:"AKAVE_ENCRYPTION_KEY_FILE" usage:"master key file; enables client-side encryption"`
+	KeyCommand string `config:"key_command" env:"AKAVE_ENCRYPTION_KEY_COMMAND" usage:"program wrapping data keys with a master key it holds; enables client-side encryption"`
+}
+
+// ChecksumsConfig controls the digests recorded for uploaded files.
+type ChecksumsConfig struct {
+	// File persists the digests; empty keeps them in memory.
+	File         string `config:"file" env:"AKAVE_CHECKSUMS_FILE" usage:"file recording the digests of uploaded files (in memory when empty)"`
+	VerifyOnRead bool   `config:"verify_on_read" env:"AKAVE_VERIFY_ON_READ" usage:"check full downloads against the recorded SHA-256"`
+}
+
+// CacheConfig enables the disk cache of recently downloaded files.
+type CacheConfig struct {
+	// Dir enables the cache; files left in it by a previous run are removed.
+	Dir         string        `config:"dir" env:"AKAVE_CACHE_DIR" usage:"directory of the download cache; enables it"`
+	MaxSize     int64         `config:"max_size" env:"AKAVE_CACHE_MAX_SIZE" usage:"total size of the cached files in bytes"`
+	MaxFileSize int64         `config:"max_file_size" env:"AKAVE_CACHE_MAX_FILE_SIZE" usage:"largest file cached in bytes; larger files bypass the cache"`
+	TTL         time.Duration `config:"ttl" env:"AKAVE_CACHE_TTL" usage:"how long a file stays cached; 0 keeps it until evicted"`
+}
+
+// WebhooksConfig enables webhooks notifying subscribed endpoints of bucket
+// and file changes.
+type WebhooksConfig struct {
+	File           string        `config:"file" env:"AKAVE_WEBHOOKS_FILE" usage:"webhook subscription file; enables webhooks"`
+	DeadLetterFile string        `config:"dead_letter_file" env:"AKAVE_WEBHOOKS_DEAD_LETTER_FILE" usage:"file that undeliverable events are appended to"`
+	MaxAttempts    int           `config:"max_attempts" env:"AKAVE_WEBHOOKS_MAX_ATTEMPTS" usage:"delivery attempts per event, including the first"`
+	InitialBackoff time.Duration `config:"initial_backoff" env:"AKAVE_WEBHOOKS_INITIAL_BACKOFF" usage:"delay before the first retry; doubles with every retry"`
+	MaxBackoff     time.Duration `config:"max_backoff" env:"AKAVE_WEBHOOKS_MAX_BACKOFF" usage:"maximum delay between retries"`
+	Timeout        time.Duration `config:"timeout" env:"AKAVE_WEBHOOKS_TIMEOUT" usage:"timeout of each delivery attempt"`
+}
+
+// Default returns the configuration used when no source overrides a field.
+func Default() Config {
+	return Config{
+		Listen: ":8080",
+		Akave: AkaveConfig{
+			MaxConcurrency:    10,
+			BlockPartSize:     1 << 20,
+			UseConnectionPool: true,
+			Retry: RetryConfig{
+				MaxAttempts:    4,
+				InitialBackoff: 200 * time.Millisecond,
+				MaxBackoff:     5 * time.Second,
+			},
+		},
+		Storage: StorageConfig{Backend: "akave"},
+		HTTP: HTTPConfig{
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Tenants: TenantsConfig{IdleTimeout: 10 * time.Minute},
+		Tus:     TusConfig{Expiration: 24 * time.Hour},
+		Jobs:    JobsConfig{Workers: 4, Retention: 24 * time.Hour},
+		S3:      S3Config{Region: "us-east-1"},
+		Metrics: MetricsConfig{Enabled: true},
+		Log:     LogConfig{Level: "info", Format: "json"},
+		Health:  HealthConfig{Timeout: 5 * time.Second},
+		Webhooks: WebhooksConfig{
+			MaxAttempts:    5,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+			Timeout:        10 * time.Second,
+		},
+		Cache: CacheConfig{
+			MaxSize:     1 << 30,
+			MaxFileSize: 64 << 20,
+			TTL:         time.Hour,
+		},
+	}
+}
+
+// FieldError reports an invalid configuration value.
+type FieldError struct {
+	// Field is the dotted key of the field, e.g. "akave.max_concurrency".
+	Field string
+	Msg   string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Msg
+}
+
+// Validate checks every field and returns all problems found, each as a
+// *FieldError.
+func (c *Config) Validate() error {
+	var errs []error
+	bad := func(field, format string, args ...any) {
+		errs = append(errs, &FieldError{Field: field, Msg: fmt.Sprintf(format, args...)})
+	}
+
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		bad("listen", "invalid address %q", c.Listen)
+	}
+
+	switch c.Storage.Backend {
+	case "akave":
+		if c.Akave.NodeAddress == "" {
+			bad("akave.node_address", "required by the akave storage backend")
+		}
+		if c.Akave.PrivateKey == "" && c.Tenants.File == "" {
+			bad("akave.private_key", "required unless tenants.file is set")
+		}
+	case "local":
+		if c.Tenants.File != "" {
+			bad("tenants.file", "tenants require the akave storage backend")
+		}
+	default:
+		bad("storage.backend", "must be akave or local, got %q", c.Storage.Backend)
+	}
+	if c.Akave.MaxConcurrency <= 0 {
+		bad("akave.max_concurrency", "must be positive, got %d", c.Akave.MaxConcurrency)
+	}
+	if c.Akave.BlockPartSize <= 0 {
+		bad("akave.block_part_size", "must be positive, got %d", c.Akave.BlockPartSize)
+	}
+	if c.Akave.Retry.MaxAttempts < 1 {
+		bad("akave.retry.max_attempts", "must be at least 1, got %d", c.Akave.Retry.MaxAttempts)
+	}
+	if c.Akave.Retry.MaxBackoff < c.Akave.Retry.InitialBackoff {
+		bad("akave.retry.max_backoff", "must not be less than akave.retry.initial_backoff (%s), got %s",
+			c.Akave.Retry.InitialBackoff, c.Akave.Retry.MaxBackoff)
+	}
+
+	for _, d := range []struct {
+		field string
+		value time.Duration
+	}{
+		{"akave.retry.initial_backoff", c.Akave.Retry.InitialBackoff},
+		{"akave.retry.max_backoff", c.Akave.Retry.MaxBackoff},
+		{"http.read_header_timeout", c.HTTP.ReadHeaderTimeout},
+		{"http.read_timeout", c.HTTP.ReadTimeout},
+		{"http.write_timeout", c.HTTP.WriteTimeout},
+		{"http.idle_timeout", c.HTTP.IdleTimeout},
+		{"http.shutdown_delay", c.HTTP.ShutdownDelay},
+		{"http.shutdown_timeout", c.HTTP.ShutdownTimeout},
+		{"tenants.idle_timeout", c.Tenants.IdleTimeout},
+		{"tus.expiration", c.Tus.Expiration},
+		{"jobs.retention", c.Jobs.Retention},
+		{"health.timeout", c.Health.Timeout},
+		{"cache.ttl", c.Cache.TTL},
+		{"webhooks.initial_backoff", c.Webhooks.InitialBackoff},
+		{"webhooks.max_backoff", c.Webhooks.MaxBackoff},
+		{"webhooks.timeout", c.Webhooks.Timeout},
+	} {
+		if d.value < 0 {
+			bad(d.field, "must not be negative, got %s", d.value)
+		}
+	}
+	if c.HTTP.MaxUploadSize < 0 {
+		bad("http.max_upload_size", "must not be negative, got %d", c.HTTP.MaxUploadSize)
+	}
+	if c.Tus.MaxSize < 0 {
+		bad("tus.max_size", "must not be negative, got %d", c.Tus.MaxSize)
+	}
+	if c.Jobs.Workers <= 0 {
+		bad("jobs.workers", "must be positive, got %d", c.Jobs.Workers)
+	}
+
+	if c.Cache.MaxSize <= 0 {
+		bad("cache.max_size", "must be positive, got %d", c.Cache.MaxSize)
+	}
+	if c.Cache.MaxFileSize <= 0 || c.Cache.MaxFileSize > c.Cache.MaxSize {
+		bad("cache.max_file_size", "must be positive and at most cache.max_size (%d), got %d", c.Cache.MaxSize, c.Cache.MaxFileSize)
+	}
+
+	if c.Webhooks.MaxAttempts < 1 {
+		bad("webhooks.max_attempts", "must be at least 1, got %d", c.Webhooks.MaxAttempts)
+	}
+	if c.Webhooks.MaxBackoff < c.Webhooks.InitialBackoff {
+		bad("webhooks.max_backoff", "must not be less than webhooks.initial_backoff (%s), got %s",
+			c.Webhooks.InitialBackoff, c.Webhooks.MaxBackoff)
+	}
+
+	if c.Tenants.File != "" && c.Auth.KeysFile == "" {
+		bad("auth.keys_file", "required when tenants.file is set")
+	}
+
+	if c.S3.Address != "" {
+		if _, _, err := net.SplitHostPort(c.S3.Address); err != nil {
+			bad("s3.address", "invalid address %q", c.S3.Address)
+		}
+		if c.S3.AccessKeyID == "" {
+			bad("s3.access_key_id", "required when s3.address is set")
+		}
+		if c.S3.SecretAccessKey == "" {
+			bad("s3.secret_access_key", "required when s3.address is set")
+		}
+		if c.S3.Region == "" {
+			bad("s3.region", "must not be empty")
+		}
+		// Without tenants a missing key has been reported already.
+		if c.Storage.Backend == "akave" && c.Akave.PrivateKey == "" && c.Tenants.File != "" {
+			bad("akave.private_key", "required by the S3 gateway")
+		}
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		bad("log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	if f := strings.ToLower(c.Log.Format); f != "json" && f != "text" {
+		bad("log.format", "must be json or text, got %q", c.Log.Format)
+	}
+
+	if c.Health.MinBalance != "" {
+		if c.Health.MinBalanceWei() == nil {
+			bad("health.min_balance", "must be a non-negative integer amount of wei, got %q", c.Health.MinBalance)
+		}
+		if c.Storage.Backend != "akave" || c.Akave.PrivateKey == "" {
+			bad("health.min_balance", "requires the akave storage backend and akave.private_key")
+		}
+	}
+
+	if c.Encryption.KeyFile != "" && c.Encryption.KeyCommand != "" {
+		bad("encryption.key_command", "cannot be combined with encryption.key_file")
+	}
+
+	if c.Metrics.Address != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Address); err != nil {
+			bad("metrics.address", "invalid address %q", c.Metrics.Address)
+		}
+	}
+
+	return errors.Join(errs...)
+}
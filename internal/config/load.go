@@ -0,0 +1,251 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable holding the configuration file
+// path when no -config flag is given.
+const FileEnv = "AKAVE_CONFIG_FILE"
+
+// field is one leaf of Config.
+type field struct {
+	key   string // dotted file key, e.g. "akave.node_address"
+	env   string
+	usage string
+	value reflect.Value
+}
+
+// flagName returns the command-line flag of the field.
+func (f field) flagName() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+// fields lists the leaves of the struct v points to.
+func fields(v reflect.Value, prefix string) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := sf.Tag.Get("config")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct && fv.Type() != reflect.TypeOf(time.Duration(0)) {
+			out = append(out, fields(fv, key)...)
+			continue
+		}
+		out = append(out, field{key: key, env: sf.Tag.Get("env"), usage: sf.Tag.Get("usage"), value: fv})
+	}
+	return out
+}
+
+// set parses s into the field.
+func (f field) set(s string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(s)
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return &FieldError{Field: f.key, Msg: fmt.Sprintf("invalid boolean %q", s)}
+		}
+		f.value.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return &FieldError{Field: f.key, Msg: fmt.Sprintf("invalid duration %q", s)}
+		}
+		f.value.SetInt(int64(d))
+	case int, int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return &FieldError{Field: f.key, Msg: fmt.Sprintf("invalid integer %q", s)}
+		}
+		f.value.SetInt(n)
+	default:
+		return &FieldError{Field: f.key, Msg: "unsupported field type " + f.value.Type().String()}
+	}
+	return nil
+}
+
+// setAny assigns a value decoded from a configuration file.
+func (f field) setAny(v any) error {
+	switch x := v.(type) {
+	case string:
+		return f.set(x)
+	case bool:
+		return f.set(strconv.FormatBool(x))
+	case int:
+		return f.set(strconv.Itoa(x))
+	case int64:
+		return f.set(strconv.FormatInt(x, 10))
+	case float64:
+		if x != float64(int64(x)) {
+			return &FieldError{Field: f.key, Msg: fmt.Sprintf("invalid integer %v", x)}
+		}
+		return f.set(strconv.FormatInt(int64(x), 10))
+	}
+	return &FieldError{Field: f.key, Msg: fmt.Sprintf("unexpected value %v", v)}
+}
+
+// Load builds the configuration from defaults, the configuration file,
+// the environment and the command-line arguments, in increasing order of
+// precedence, and validates the result. The file is named by the -config
+// flag or, failing that, by the AKAVE_CONFIG_FILE environment variable.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+	leaves := fields(reflect.ValueOf(&cfg).Elem(), "")
+
+	fs := flag.NewFlagSet("akavelink", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configFile := fs.String("config", "", "YAML or TOML configuration file")
+	flagValues := make(map[string]string)
+	for _, f := range leaves {
+		name := f.flagName()
+		usage := f.usage
+		if f.env != "" {
+			usage += " (env " + f.env + ")"
+		}
+		record := func(s string) error {
+			flagValues[name] = s
+			return nil
+		}
+		if f.value.Kind() == reflect.Bool {
+			fs.BoolFunc(name, usage, record)
+		} else {
+			fs.Func(name, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	path := *configFile
+	if path == "" {
+		path = getenv(FileEnv)
+	}
+	if path != "" {
+		if err := loadFile(path, leaves); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, f := range leaves {
+		if v := getenv(f.env); f.env != "" && v != "" {
+			if err := f.set(v); err != nil {
+				return Config{}, fmt.Errorf("%s: %w", f.env, err)
+			}
+		}
+	}
+
+	for _, f := range leaves {
+		if v, ok := flagValues[f.flagName()]; ok {
+			if err := f.set(v); err != nil {
+				return Config{}, fmt.Errorf("-%s: %w", f.flagName(), err)
+			}
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Usage writes the flag documentation to w.
+func Usage(w io.Writer) {
+	cfg := Default()
+	fmt.Fprintln(w, "Usage of akavelink:")
+	fmt.Fprintln(w, "  -config string\n    \tYAML or TOML configuration file (env "+FileEnv+")")
+	for _, f := range fields(reflect.ValueOf(&cfg).Elem(), "") {
+		fmt.Fprintf(w, "  -%s %s\n    \t%s (env %s, default %v)\n", f.flagName(), f.value.Type().Name(), f.usage, f.env, f.value.Interface())
+	}
+}
+
+// loadFile applies the values of a YAML (.yaml, .yml) or TOML (.toml) file.
+func loadFile(path string, leaves []field) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	raw := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &raw)
+	case ".toml":
+		err = toml.Unmarshal(b, &raw)
+	default:
+		return fmt.Errorf("unsupported config file extension %q; use .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	byKey := make(map[string]field, len(leaves))
+	for _, f := range leaves {
+		byKey[f.key] = f
+	}
+	values := make(map[string]any)
+	if err := flatten(raw, "", values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, k := range keys {
+		f, ok := byKey[k]
+		if !ok {
+			errs = append(errs, &FieldError{Field: k, Msg: "unknown setting"})
+			continue
+		}
+		if err := f.setAny(values[k]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// flatten turns nested tables into dotted keys.
+func flatten(m map[string]any, prefix string, out map[string]any) error {
+	for k, v := range m {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]any); ok {
+			if err := flatten(sub, key, out); err != nil {
+				return err
+			}
+			continue
+		}
+		out[key] = v
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Package httprange parses HTTP Range headers (RFC 9110, section 14) and
+// evaluates If-Range preconditions.
+package httprange
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRanges caps the number of ranges accepted from a single Range header.
+const MaxRanges = 16
+
+var (
+	// ErrInvalid marks a malformed Range header, which servers should ignore.
+	ErrInvalid = errors.New("invalid range")
+	// ErrNoOverlap marks a Range header none of whose ranges intersect the content.
+	ErrNoOverlap = errors.New("range not satisfiable")
+)
+
+// Range is a byte range with Length bytes starting at Start.
+type Range struct {
+	Start, Length int64
+}
+
+// ContentRange formats r as a Content-Range header value.
+func (r Range) ContentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.Start, r.Start+r.Length-1, size)
+}
+
+// MIMEHeader returns the part header of r inside a multipart/byteranges body.
+func (r Range) MIMEHeader(contentType string, size int64) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Range": {r.ContentRange(size)},
+		"Content-Type":  {contentType},
+	}
+}
+
+// Unsatisfied formats the Content-Range value sent with a 416 response.
+func Unsatisfied(size int64) string {
+	return fmt.Sprintf("bytes */%d", size)
+}
+
+// Parse parses a Range header of the form "bytes=0-99,200-,-50" against
+// content of the given size. Ranges that start past the end of the content
+// are dropped; if none remain ErrNoOverlap is returned.
+func Parse(s string, size int64) ([]Range, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(s, prefix) {
+		return nil, ErrInvalid
+	}
+
+	var ranges []Range
+	noOverlap := false
+	for _, spec := range strings.Split(s[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		startStr, endStr, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, ErrInvalid
+		}
+		startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+
+		var r Range
+		if startStr == "" {
+			// suffix range: the last n bytes
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || n < 0 {
+				return nil, ErrInvalid
+			}
+			if n == 0 || size == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r = Range{Start: size - n, Length: n}
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, ErrInvalid
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+			end := size - 1
+			if endStr != "" {
+				end, err = strconv.ParseInt(endStr, 10, 64)
+				if err != nil || end < start {
+					return nil, ErrInvalid
+				}
+				if end >= size {
+					end = size - 1
+				}
+			}
+			r = Range{Start: start, Length: end - start + 1}
+		}
+		ranges = append(ranges, r)
+	}
+
+	if len(ranges) > MaxRanges {
+		return nil, ErrInvalid
+	}
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, ErrNoOverlap
+		}
+		return nil, ErrInvalid
+	}
+	return ranges, nil
+}
+
+// TotalLength returns the number of bytes covered by ranges.
+func TotalLength(ranges []Range) int64 {
+	var total int64
+	for _, r := range ranges {
+		total += r.Length
+	}
+	return total
+}
+
+// IfRangeMatches reports whether the Range header of r should be honoured
+// given its If-Range precondition. Entity tags must match etag strongly;
+// dates must equal modTime to the second.
+func IfRangeMatches(r *http.Request, etag string, modTime time.Time) bool {
+	v := strings.TrimSpace(r.Header.Get("If-Range"))
+	if v == "" {
+		return true
+	}
+	if strings.HasPrefix(v, `"`) || strings.HasPrefix(v, "W/") {
+		return etag != "" && v == etag
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return false
+	}
+	return !modTime.IsZero() && modTime.Truncate(time.Second).Equal(t)
+}
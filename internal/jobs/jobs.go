@@ -0,0 +1,349 @@
+// Package jobs commits uploads to storage in the background. The body of an
+// upload is spooled to a local directory first, so the request returns as
+// soon as the bytes are on disk; a pool of workers then hands the spooled
+// data to storage. Jobs are persisted next to their data and resume after a
+// restart.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+// ErrNotFound is returned for unknown and removed jobs.
+var ErrNotFound = errors.New("job not found")
+
+// State is the stage a job is in.
+type State string
+
+// Job states.
+const (
+	// Queued jobs wait for a free worker.
+	Queued State = "queued"
+	// Running jobs are being committed to storage.
+	Running State = "running"
+	// Succeeded jobs were committed; Result holds the stored file.
+	Succeeded State = "succeeded"
+	// Failed jobs were not committed; Error says why.
+	Failed State = "failed"
+)
+
+// Job is the persisted state of a single background upload.
+type Job struct {
+	ID       string `json:"id"`
+	Bucket   string `json:"bucket"`
+	FileName string `json:"fileName"`
+	State    State  `json:"state"`
+	// Size is the number of bytes spooled.
+	Size int64 `json:"size"`
+	// Progress is the number of bytes handed to storage so far.
+	Progress int64 `json:"progress"`
+	// Result is set once the job succeeded.
+	Result *akavesdk.FileMeta `json:"result,omitempty"`
+	// Error and Code describe why the job failed.
+	Error string `json:"error,omitempty"`
+	Code  string `json:"code,omitempty"`
+	// Owner and Tenant identify the API key that submitted the job.
+	Owner  string `json:"owner,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+	// Metadata holds request details the RunFunc needs, such as the
+	// digests declared by the client.
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// Finished reports whether the job succeeded or failed.
+func (j Job) Finished() bool {
+	return j.State == Succeeded || j.State == Failed
+}
+
+// RunFunc commits the spooled body of job to storage.
+type RunFunc func(ctx context.Context, job Job, body io.Reader) (akavesdk.FileMeta, error)
+
+// Config controls the spool directory and workers of a Manager.
+type Config struct {
+	// Dir is the directory spooled uploads and job states are written to.
+	Dir string
+	// Workers is the number of jobs run at once. Zero defaults to 4.
+	Workers int
+	// Retention is how long finished jobs can still be queried. Zero
+	// defaults to 24 hours.
+	Retention time.Duration
+	// Classify returns the error code recorded with a failed job. Nil
+	// records no code.
+	Classify func(error) string
+}
+
+// Manager queues jobs and runs them on a pool of workers.
+type Manager struct {
+	cfg   Config
+	store *store
+	run   RunFunc
+	now   func() time.Time
+	wake  chan struct{}
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	pending []string
+}
+
+// NewManager creates the spool directory and loads the jobs persisted in
+// it. Jobs that were queued or running when the previous process stopped
+// are queued again and run once Run is called.
+func NewManager(cfg Config, run RunFunc) (*Manager, error) {
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+	st, err := newStore(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{
+		cfg:   cfg,
+		store: st,
+		run:   run,
+		now:   time.Now,
+		wake:  make(chan struct{}, 1),
+		jobs:  make(map[string]*Job),
+	}
+
+	loaded, err := st.load()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].CreatedAt.Before(loaded[j].CreatedAt) })
+	for _, job := range loaded {
+		if !job.Finished() {
+			if st.hasData(job.ID) {
+				job.State, job.Progress = Queued, 0
+				m.pending = append(m.pending, job.ID)
+			} else {
+				job.State, job.Error = Failed, "spooled data was lost"
+			}
+			job.UpdatedAt = m.now()
+			if err := st.save(job); err != nil {
+				return nil, err
+			}
+		}
+		m.jobs[job.ID] = &job
+	}
+	if len(m.pending) > 0 {
+		slog.Info("jobs: resuming queued uploads", "count", len(m.pending))
+	}
+	return m, nil
+}
+
+// Submit spools body and queues a job uploading it as job.FileName into
+// job.Bucket. It returns once the body is on disk; failing to read body
+// fails the submission with the read error wrapped.
+func (m *Manager) Submit(job Job, body io.Reader) (Job, error) {
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	now := m.now()
+	job.ID, job.State, job.Progress = id, Queued, 0
+	job.Result, job.Error, job.Code = nil, "", ""
+	job.CreatedAt, job.UpdatedAt = now, now
+
+	if job.Size, err = m.store.spool(id, body); err != nil {
+		return Job{}, err
+	}
+	if err := m.store.save(job); err != nil {
+		m.store.remove(id)
+		return Job{}, err
+	}
+
+	queued := job
+	m.mu.Lock()
+	m.jobs[id] = &queued
+	m.pending = append(m.pending, id)
+	m.mu.Unlock()
+	m.signal()
+	return job, nil
+}
+
+// Get returns the current state of a job.
+func (m *Manager) Get(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return *job, nil
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every
+// running job has returned. Jobs interrupted by the cancellation are
+// queued again for the next start.
+func (m *Manager) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range m.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// RunJanitor removes finished jobs older than the retention every interval
+// until ctx is cancelled.
+func (m *Manager) RunJanitor(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if n := m.sweep(m.now()); n > 0 {
+				slog.Info("jobs: removed finished jobs", "count", n)
+			}
+		}
+	}
+}
+
+// signal wakes an idle worker.
+func (m *Manager) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, ok := m.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+			case <-m.wake:
+			}
+			continue
+		}
+		m.process(ctx, job)
+	}
+}
+
+// next marks the oldest queued job as running and returns it.
+func (m *Manager) next() (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.pending) == 0 {
+		return Job{}, false
+	}
+	id := m.pending[0]
+	m.pending = m.pending[1:]
+	// Wakeups coalesce, so pass one on while work is left.
+	if len(m.pending) > 0 {
+		m.signal()
+	}
+	job := m.jobs[id]
+	job.State, job.UpdatedAt = Running, m.now()
+	if err := m.store.save(*job); err != nil {
+		slog.Error("jobs: failed to persist job state", "job", id, "error", err)
+	}
+	return *job, true
+}
+
+// process runs a job and records its outcome.
+func (m *Manager) process(ctx context.Context, job Job) {
+	f, err := m.store.open(job.ID)
+	if err != nil {
+		m.finish(job.ID, akavesdk.FileMeta{}, fmt.Errorf("spooled data was lost: %w", err))
+		return
+	}
+	meta, err := m.run(ctx, job, &progressReader{r: f, m: m, id: job.ID})
+	f.Close()
+	if err != nil && ctx.Err() != nil {
+		m.requeue(job.ID)
+		return
+	}
+	m.finish(job.ID, meta, err)
+}
+
+// finish records the outcome of a job and discards its spooled data.
+func (m *Manager) finish(id string, meta akavesdk.FileMeta, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	job.UpdatedAt = m.now()
+	if err != nil {
+		job.State, job.Error = Failed, err.Error()
+		if m.cfg.Classify != nil {
+			job.Code = m.cfg.Classify(err)
+		}
+		slog.Warn("jobs: upload failed", "job", id, "bucket", job.Bucket, "file", job.FileName, "error", err)
+	} else {
+		job.State, job.Result, job.Progress = Succeeded, &meta, job.Size
+	}
+	if err := m.store.save(*job); err != nil {
+		slog.Error("jobs: failed to persist job state", "job", id, "error", err)
+	}
+	m.store.discardData(id)
+}
+
+// requeue puts back a job interrupted by shutdown. It is not run again by
+// this process but by the next one, which loads it as queued.
+func (m *Manager) requeue(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	job.State, job.Progress, job.UpdatedAt = Queued, 0, m.now()
+	if err := m.store.save(*job); err != nil {
+		slog.Error("jobs: failed to persist job state", "job", id, "error", err)
+	}
+}
+
+// advance adds n bytes to the progress of a running job.
+func (m *Manager) advance(id string, n int) {
+	m.mu.Lock()
+	m.jobs[id].Progress += int64(n)
+	m.mu.Unlock()
+}
+
+// sweep removes finished jobs last updated more than the retention before
+// now and returns their count.
+func (m *Manager) sweep(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	removed := 0
+	for id, job := range m.jobs {
+		if job.Finished() && now.Sub(job.UpdatedAt) > m.cfg.Retention {
+			m.store.remove(id)
+			delete(m.jobs, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// progressReader reports the bytes read through it as job progress.
+type progressReader struct {
+	r  io.Reader
+	m  *Manager
+	id string
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.m.advance(p.id, n)
+	}
+	return n, err
+}
@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// store keeps spooled uploads and job states on the local disk as a pair of
+// files per job: <id>.bin with the spooled body and <id>.json with the Job.
+type store struct {
+	dir string
+}
+
+func newStore(dir string) (*store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	return &store{dir: dir}, nil
+}
+
+func (s *store) dataPath(id string) string { return filepath.Join(s.dir, id+".bin") }
+func (s *store) infoPath(id string) string { return filepath.Join(s.dir, id+".json") }
+
+// load returns every persisted job and removes spooled data no job refers
+// to.
+func (s *store) load() ([]Job, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+	var loaded []Job
+	known := make(map[string]bool)
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || !validID(id) {
+			continue
+		}
+		b, err := os.ReadFile(s.infoPath(id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read job %s: %w", id, err)
+		}
+		var job Job
+		if err := json.Unmarshal(b, &job); err != nil {
+			return nil, fmt.Errorf("corrupt job state %s: %w", id, err)
+		}
+		loaded = append(loaded, job)
+		known[id] = true
+	}
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".bin"); ok && validID(id) && !known[id] {
+			os.Remove(s.dataPath(id))
+		}
+	}
+	return loaded, nil
+}
+
+// spool writes body to the data file of a new job and returns its size.
+func (s *store) spool(id string, body io.Reader) (int64, error) {
+	f, err := os.OpenFile(s.dataPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create spool file: %w", err)
+	}
+	n, err := io.Copy(f, body)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(s.dataPath(id))
+		return 0, fmt.Errorf("failed to spool upload: %w", err)
+	}
+	return n, nil
+}
+
+// save atomically persists job.
+func (s *store) save(job Job) error {
+	b, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(job.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("failed to persist job state: %w", err)
+	}
+	return os.Rename(tmp, s.infoPath(job.ID))
+}
+
+// open returns the spooled data for reading.
+func (s *store) open(id string) (*os.File, error) {
+	return os.Open(s.dataPath(id))
+}
+
+// hasData reports whether the spooled data of a job still exists.
+func (s *store) hasData(id string) bool {
+	_, err := os.Stat(s.dataPath(id))
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// discardData removes the spooled data but keeps the state file, so a
+// finished job can still be queried until it is swept.
+func (s *store) discardData(id string) {
+	os.Remove(s.dataPath(id))
+}
+
+// remove deletes every trace of a job.
+func (s *store) remove(id string) {
+	os.Remove(s.dataPath(id))
+	os.Remove(s.infoPath(id))
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validID guards file system paths against anything but generated IDs.
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
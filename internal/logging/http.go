@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/felixge/httpsnoop"
+)
+
+// RequestIDHeader is the header a request ID is read from and echoed in.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs.
+const maxRequestIDLength = 128
+
+// RequestIDs assigns every request an ID: the caller's X-Request-ID if it
+// is a reasonable token, otherwise a random one. The ID is echoed in the
+// X-Request-ID response header, which is set before next runs, and is
+// available from the request context via RequestID.
+func RequestIDs(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts printable ASCII without spaces, so IDs cannot
+// break log lines or response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AccessLog logs one record per request served by next to the default
+// logger, with its method, route, status, response size, duration and any
+// attributes added with Annotate. route names the request's route, e.g.
+// "/buckets/{bucket}".
+func AccessLog(route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, f := withFields(r.Context())
+			r = r.WithContext(ctx)
+			m := httpsnoop.CaptureMetrics(next, w, r)
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", route(r)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", m.Code),
+				slog.Int64("bytes", m.Written),
+				slog.Duration("duration", m.Duration),
+				slog.String("remote", r.RemoteAddr),
+			}
+			f.mu.Lock()
+			attrs = append(attrs, f.attrs...)
+			f.mu.Unlock()
+
+			level := slog.LevelInfo
+			if m.Code >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			slog.Default().LogAttrs(ctx, level, "request", attrs...)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Package metrics defines the Prometheus metrics of the server and the
+// helpers that record them.
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Transfer directions used as the "direction" label.
+const (
+	Upload   = "upload"
+	Download = "download"
+)
+
+// durationBuckets spans quick metadata calls as well as multi-gigabyte
+// transfers: 5ms up to roughly 20 minutes.
+var durationBuckets = prometheus.ExponentialBuckets(0.005, 4, 10)
+
+// Registry holds every metric of the server. It is separate from the
+// default registry so that only the collectors below are exposed.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "akavelink_http_requests_total",
+		Help: "HTTP requests served, by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "akavelink_http_request_duration_seconds",
+		Help:    "Time spent serving HTTP requests, by route, method and status.",
+		Buckets: durationBuckets,
+	}, []string{"route", "method", "status"})
+
+	transferBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "akavelink_transfer_bytes_total",
+		Help: "File content bytes uploaded to or downloaded from storage.",
+	}, []string{"direction"})
+
+	activeTransfers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "akavelink_active_transfers",
+		Help: "Uploads and downloads in progress.",
+	}, []string{"direction"})
+
+	checksumMismatches = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "akavelink_checksum_mismatches_total",
+		Help: "Uploads and downloads whose content did not match the expected digest.",
+	}, []string{"direction"})
+
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "akavelink_cache_lookups_total",
+		Help: "Downloads looked up in the disk cache, by result (HIT, MISS or BYPASS).",
+	}, []string{"result"})
+
+	cacheSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "akavelink_cache_size_bytes",
+		Help: "Total size of the files held in the disk cache.",
+	})
+
+	webhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "akavelink_webhook_deliveries_total",
+		Help: "Webhook delivery attempts, by result (delivered, retried or dead_lettered).",
+	}, []string{"result"})
+
+	sdkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "akavelink_sdk_call_duration_seconds",
+		Help:    "Latency of Akave SDK calls, by operation.",
+		Buckets: durationBuckets,
+	}, []string{"operation"})
+
+	sdkErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "akavelink_sdk_call_errors_total",
+		Help: "Failed Akave SDK calls, by operation and error kind.",
+	}, []string{"operation", "kind"})
+
+	sdkRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "akavelink_sdk_call_retries_total",
+		Help: "Akave SDK calls retried after a transient error, by operation and error kind.",
+	}, []string{"operation", "kind"})
+
+	sdkClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "akavelink_sdk_clients_open",
+		Help: "Akave SDK clients currently open, including tenant clients.",
+	})
+
+	tenantOpens = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "akavelink_tenant_client_opens_total",
+		Help: "Tenant clients opened by the tenant pool.",
+	})
+
+	tenantEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "akavelink_tenant_client_evictions_total",
+		Help: "Idle tenant clients closed by the tenant pool.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		transferBytes, activeTransfers, checksumMismatches,
+		cacheLookups, cacheSize, webhookDeliveries,
+		sdkDuration, sdkErrors, sdkRetries, sdkClients,
+		tenantOpens, tenantEvictions,
+	)
+	for _, dir := range []string{Upload, Download} {
+		transferBytes.WithLabelValues(dir)
+		activeTransfers.WithLabelValues(dir)
+		checksumMismatches.WithLabelValues(dir)
+	}
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Transfer is an upload or download in progress.
+type Transfer struct {
+	bytes prometheus.Counter
+	done  func()
+}
+
+// BeginTransfer counts an upload or download as active until Done is
+// called. Bytes passing through its Reader or Writer are counted as they
+// flow, so long transfers show up before they finish.
+func BeginTransfer(direction string) *Transfer {
+	active := activeTransfers.WithLabelValues(direction)
+	active.Inc()
+	return &Transfer{bytes: transferBytes.WithLabelValues(direction), done: active.Dec}
+}
+
+// Reader counts the bytes read from r.
+func (t *Transfer) Reader(r io.Reader) io.Reader {
+	return &countingReader{r: r, bytes: t.bytes}
+}
+
+// Writer counts the bytes written to w.
+func (t *Transfer) Writer(w io.Writer) io.Writer {
+	return &countingWriter{w: w, bytes: t.bytes}
+}
+
+// Done marks the transfer as no longer active.
+func (t *Transfer) Done() {
+	t.done()
+}
+
+type countingReader struct {
+	r     io.Reader
+	bytes prometheus.Counter
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.bytes.Add(float64(n))
+	return n, err
+}
+
+type countingWriter struct {
+	w     io.Writer
+	bytes prometheus.Counter
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.bytes.Add(float64(n))
+	return n, err
+}
+
+// ChecksumMismatch counts an upload or download whose content did not
+// match the expected digest.
+func ChecksumMismatch(direction string) {
+	checksumMismatches.WithLabelValues(direction).Inc()
+}
+
+// CacheLookup counts a download looked up in the disk cache.
+func CacheLookup(result string) {
+	cacheLookups.WithLabelValues(result).Inc()
+}
+
+// SetCacheSize records the total size of the files in the disk cache.
+func SetCacheSize(bytes int64) { cacheSize.Set(float64(bytes)) }
+
+// WebhookDelivery counts a webhook delivery attempt.
+func WebhookDelivery(result string) {
+	webhookDeliveries.WithLabelValues(result).Inc()
+}
+
+// ObserveSDKCall records one SDK call. kind is empty for successful calls
+// and otherwise names the kind of error returned.
+func ObserveSDKCall(operation string, d time.Duration, kind string) {
+	sdkDuration.WithLabelValues(operation).Observe(d.Seconds())
+	if kind != "" {
+		sdkErrors.WithLabelValues(operation, kind).Inc()
+	}
+}
+
+// SDKRetry counts a retry of an SDK call that failed with an error of
+// the given kind.
+func SDKRetry(operation, kind string) {
+	sdkRetries.WithLabelValues(operation, kind).Inc()
+}
+
+// SDKClientOpened counts a newly opened SDK client.
+func SDKClientOpened() { sdkClients.Inc() }
+
+// SDKClientClosed counts a closed SDK client.
+func SDKClientClosed() { sdkClients.Dec() }
+
+// TenantClientOpened counts a client opened by the tenant pool.
+func TenantClientOpened() { tenantOpens.Inc() }
+
+// TenantClientsEvicted counts idle clients closed by the tenant pool.
+func TenantClientsEvicted(n int) { tenantEvictions.Add(float64(n)) }
+
+// RegisterTenantPool exposes the number of open tenant clients, read from
+// size on every scrape.
+func RegisterTenantPool(size func() int) error {
+	return Registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "akavelink_tenant_clients_open",
+		Help: "Tenant clients currently held open by the tenant pool.",
+	}, func() float64 { return float64(size()) }))
+}
@@ -0,0 +1,334 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signV4Algorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	scopeDateFormat = "20060102"
+	serviceName     = "s3"
+	scopeTerminator = "aws4_request"
+
+	// maxClockSkew is how far a request's X-Amz-Date may drift from server time.
+	maxClockSkew = 15 * time.Minute
+	// maxPresignExpiry is the longest validity SigV4 allows for presigned URLs.
+	maxPresignExpiry = 7 * 24 * time.Hour
+
+	unsignedPayload         = "UNSIGNED-PAYLOAD"
+	streamingPayload        = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrail  = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Credentials is an S3 access key pair accepted by the gateway.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Signature is the verified SigV4 signature of a request.
+type Signature struct {
+	// AccessKeyID identifies the credentials that signed the request.
+	AccessKeyID string
+	// PayloadHash is the x-amz-content-sha256 value the signature covers.
+	PayloadHash string
+
+	signingKey []byte
+	amzDate    string
+	scope      string
+	seed       string
+}
+
+// Authenticator verifies AWS Signature Version 4 requests, both header
+// based and presigned URLs.
+type Authenticator struct {
+	// Region is the signing region clients must use.
+	Region string
+	// Now returns the current time; it defaults to time.Now.
+	Now func() time.Time
+
+	secrets map[string]string
+}
+
+// NewAuthenticator returns an Authenticator accepting the given credentials.
+func NewAuthenticator(region string, creds ...Credentials) *Authenticator {
+	secrets := make(map[string]string, len(creds))
+	for _, c := range creds {
+		secrets[c.AccessKeyID] = c.SecretAccessKey
+	}
+	return &Authenticator{Region: region, Now: time.Now, secrets: secrets}
+}
+
+// Verify checks the SigV4 signature of r and returns it on success.
+// Errors are returned as *Error values carrying the S3 error code.
+func (a *Authenticator) Verify(r *http.Request) (*Signature, error) {
+	if r.URL.Query().Get("X-Amz-Algorithm") != "" {
+		return a.verifyPresigned(r)
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return nil, errAccessDenied
+	}
+	if !strings.HasPrefix(auth, signV4Algorithm+" ") {
+		return nil, errSignatureVersion
+	}
+	fields := parseAuthFields(strings.TrimPrefix(auth, signV4Algorithm+" "))
+	credential, signedHeaders, signature := fields["Credential"], fields["SignedHeaders"], fields["Signature"]
+	if credential == "" || signedHeaders == "" || signature == "" {
+		return nil, errAuthorizationHeaderMalformed
+	}
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		amzDate = r.Header.Get("Date")
+	}
+	t, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil {
+		return nil, errMissingDate
+	}
+	if skew := a.now().Sub(t); skew > maxClockSkew || skew < -maxClockSkew {
+		return nil, errRequestTimeTooSkewed
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return nil, errMissingContentSHA256
+	}
+
+	return a.check(r, credential, signedHeaders, signature, amzDate, payloadHash, r.URL.Query())
+}
+
+func (a *Authenticator) verifyPresigned(r *http.Request) (*Signature, error) {
+	q := r.URL.Query()
+	if q.Get("X-Amz-Algorithm") != signV4Algorithm {
+		return nil, errSignatureVersion
+	}
+	amzDate := q.Get("X-Amz-Date")
+	t, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil {
+		return nil, errMissingDate
+	}
+	expires, err := strconv.ParseInt(q.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxPresignExpiry {
+		return nil, errAuthorizationQueryMalformed
+	}
+	now := a.now()
+	if now.Before(t.Add(-maxClockSkew)) {
+		return nil, errRequestNotReadyYet
+	}
+	if now.After(t.Add(time.Duration(expires) * time.Second)) {
+		return nil, errExpiredPresignRequest
+	}
+
+	signature := q.Get("X-Amz-Signature")
+	query := url.Values{}
+	for k, v := range q {
+		if k != "X-Amz-Signature" {
+			query[k] = v
+		}
+	}
+	return a.check(r, q.Get("X-Amz-Credential"), q.Get("X-Amz-SignedHeaders"), signature, amzDate, unsignedPayload, query)
+}
+
+// check recomputes the signature of r and compares it with signature.
+func (a *Authenticator) check(r *http.Request, credential, signedHeaders, signature, amzDate, payloadHash string, query url.Values) (*Signature, error) {
+	// Credential is <key>/<date>/<region>/<service>/aws4_request
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != scopeTerminator || parts[3] != serviceName {
+		return nil, errCredentialMalformed
+	}
+	accessKey, scopeDate, region := parts[0], parts[1], parts[2]
+	secret, ok := a.secrets[accessKey]
+	if !ok {
+		return nil, errInvalidAccessKeyID
+	}
+	if region != a.Region {
+		return nil, errAuthorizationHeaderMalformed.withMessage("the region '" + region + "' is wrong; expecting '" + a.Region + "'")
+	}
+	if !strings.HasPrefix(amzDate, scopeDate) {
+		return nil, errCredentialMalformed
+	}
+
+	headers := strings.Split(signedHeaders, ";")
+	if !containsString(headers, "host") {
+		return nil, errAuthorizationHeaderMalformed
+	}
+
+	canonical := strings.Join([]string{
+		r.Method,
+		canonicalURI(r.URL.Path),
+		canonicalQuery(query),
+		canonicalHeaders(r, headers),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{scopeDate, region, serviceName, scopeTerminator}, "/")
+	stringToSign := strings.Join([]string{signV4Algorithm, amzDate, scope, sha256Hex([]byte(canonical))}, "\n")
+	key := signingKey(secret, scopeDate, region)
+	expected := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, errSignatureDoesNotMatch
+	}
+	return &Signature{
+		AccessKeyID: accessKey,
+		PayloadHash: payloadHash,
+		signingKey:  key,
+		amzDate:     amzDate,
+		scope:       scope,
+		seed:        signature,
+	}, nil
+}
+
+// chunkSignature computes the signature of one aws-chunked payload chunk.
+func (s *Signature) chunkSignature(prev string, chunkHash string) string {
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD",
+		s.amzDate,
+		s.scope,
+		prev,
+		emptySHA256,
+		chunkHash,
+	}, "\n")
+	return hex.EncodeToString(hmacSHA256(s.signingKey, []byte(stringToSign)))
+}
+
+func (a *Authenticator) now() time.Time {
+	if a.Now != nil {
+		return a.Now()
+	}
+	return time.Now()
+}
+
+// parseAuthFields splits "Credential=..., SignedHeaders=..., Signature=..."
+// into its key/value pairs.
+func parseAuthFields(s string) map[string]string {
+	fields := make(map[string]string, 3)
+	for _, f := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(f), "=")
+		if ok {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+// canonicalURI encodes path the way S3 clients do before signing: every
+// byte except unreserved characters and '/' is percent-encoded once.
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return uriEncode(path, false)
+}
+
+// canonicalQuery sorts and encodes the query parameters.
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			pairs = append(pairs, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders renders the signed headers as "name:value\n" lines.
+func canonicalHeaders(r *http.Request, signed []string) string {
+	var b strings.Builder
+	for _, name := range signed {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = r.Header.Get("Content-Length")
+			if value == "" && r.ContentLength >= 0 {
+				value = strconv.FormatInt(r.ContentLength, 10)
+			}
+		case "transfer-encoding":
+			value = strings.Join(r.TransferEncoding, ",")
+		default:
+			vals := r.Header.Values(name)
+			for i, v := range vals {
+				vals[i] = strings.Join(strings.Fields(v), " ")
+			}
+			value = strings.Join(vals, ",")
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// uriEncode implements the UriEncode function of the SigV4 specification.
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+	}
+	return b.String()
+}
+
+func signingKey(secret, date, region string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	k = hmacSHA256(k, []byte(region))
+	k = hmacSHA256(k, []byte(serviceName))
+	return hmacSHA256(k, []byte(scopeTerminator))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package s3
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
+)
+
+// defaultMaxKeys is the page size of object listings when max-keys is absent.
+const defaultMaxKeys = 1000
+
+func (g *Gateway) listBuckets(w http.ResponseWriter, r *http.Request) {
+	buckets, err := g.client.ListBuckets(r.Context())
+	if err != nil {
+		writeError(w, r, toS3Error(err))
+		return
+	}
+
+	res := listAllMyBucketsResult{
+		Xmlns: s3Namespace,
+		Owner: owner{ID: "akave", DisplayName: "akave"},
+	}
+	for _, b := range buckets {
+		res.Buckets = append(res.Buckets, bucketEntry{Name: b.Name, CreationDate: formatTime(b.CreatedAt)})
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+func (g *Gateway) createBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	_, err := g.client.CreateBucket(r.Context(), bucket)
+	if errors.Is(err, akavesdk.ErrAlreadyExists) {
+		writeError(w, r, errBucketAlreadyOwned)
+		return
+	}
+	if err != nil {
+		writeError(w, r, toS3Error(err))
+		return
+	}
+	g.emit(webhook.Event{Type: webhook.BucketCreated, Bucket: bucket})
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *Gateway) headBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	if _, err := g.client.ViewBucket(r.Context(), bucket); err != nil {
+		writeError(w, r, bucketError(err))
+		return
+	}
+	w.Header().Set("x-amz-bucket-region", g.auth.Region)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *Gateway) deleteBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	if err := g.client.DeleteBucket(r.Context(), bucket); err != nil {
+		writeError(w, r, bucketError(err))
+		return
+	}
+	g.emit(webhook.Event{Type: webhook.BucketDeleted, Bucket: bucket})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (g *Gateway) getBucketLocation(w http.ResponseWriter, r *http.Request, bucket string) {
+	if _, err := g.client.ViewBucket(r.Context(), bucket); err != nil {
+		writeError(w, r, bucketError(err))
+		return
+	}
+	loc := g.auth.Region
+	if loc == "us-east-1" {
+		// S3 reports the default region as an empty constraint.
+		loc = ""
+	}
+	writeXML(w, http.StatusOK, locationConstraint{Xmlns: s3Namespace, Location: loc})
+}
+
+// bucketError maps errors of bucket-level operations, where a generic "not
+// found" refers to the bucket rather than a key.
+func bucketError(err error) *Error {
+	if e := toS3Error(err); e != errNoSuchKey {
+		return e
+	}
+	return errNoSuchBucket
+}
+
+// listToken is the decoded form of a ListObjectsV2 continuation token.
+type listToken struct {
+	// LastKey is the last key consumed by the previous page.
+	LastKey string `json:"k"`
+	// Prefix is the common prefix the previous page ended in, if any.
+	Prefix string `json:"p,omitempty"`
+}
+
+func (t listToken) encode() string {
+	b, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeListToken(s string) (listToken, error) {
+	var t listToken
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, err
+	}
+	return t, json.Unmarshal(b, &t)
+}
+
+// listObjects implements ListObjectsV2 (list-type=2) and the legacy
+// ListObjects API, including delimiter roll-up into common prefixes.
+func (g *Gateway) listObjects(w http.ResponseWriter, r *http.Request, bucket string) {
+	q := r.URL.Query()
+	v2 := q.Get("list-type") == "2"
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+
+	maxKeys := defaultMaxKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, r, errInvalidArgument.withMessage("max-keys must be a non-negative integer"))
+			return
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	var start listToken
+	switch {
+	case v2 && q.Get("continuation-token") != "":
+		t, err := decodeListToken(q.Get("continuation-token"))
+		if err != nil {
+			writeError(w, r, errInvalidArgument.withMessage("The continuation token provided is incorrect"))
+			return
+		}
+		start = t
+	case v2:
+		start.LastKey = q.Get("start-after")
+	default:
+		start.LastKey = q.Get("marker")
+		if delimiter != "" && strings.HasSuffix(start.LastKey, delimiter) {
+			start.Prefix = start.LastKey
+		}
+	}
+
+	page, err := g.collect(r, bucket, prefix, delimiter, start, maxKeys)
+	if err != nil {
+		writeError(w, r, bucketError(err))
+		return
+	}
+
+	encode := func(s string) string { return s }
+	res := listBucketResult{
+		Xmlns:       s3Namespace,
+		Name:        bucket,
+		MaxKeys:     maxKeys,
+		IsTruncated: page.truncated,
+	}
+	if q.Get("encoding-type") == "url" {
+		encode = url.QueryEscape
+		res.EncodingType = "url"
+	}
+	res.Prefix = encode(prefix)
+	res.Delimiter = encode(delimiter)
+	for _, f := range page.files {
+		res.Contents = append(res.Contents, object{
+			Key:          encode(f.Name),
+			LastModified: formatTime(f.CreatedAt),
+			ETag:         `"` + f.RootCID + `"`,
+			Size:         f.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+	for _, p := range page.prefixes {
+		res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{Prefix: encode(p)})
+	}
+
+	if v2 {
+		keyCount := len(res.Contents) + len(res.CommonPrefixes)
+		res.KeyCount = &keyCount
+		res.ContinuationToken = q.Get("continuation-token")
+		res.StartAfter = encode(q.Get("start-after"))
+		if page.truncated {
+			res.NextContinuationToken = page.next.encode()
+		}
+	} else {
+		marker := encode(q.Get("marker"))
+		res.Marker = &marker
+		if page.truncated && delimiter != "" {
+			res.NextMarker = encode(page.next.LastKey)
+			if page.next.Prefix != "" {
+				res.NextMarker = encode(page.next.Prefix)
+			}
+		}
+	}
+	writeXML(w, http.StatusOK, res)
+}
+
+// listPage is one page of keys and common prefixes.
+type listPage struct {
+	files     []akavesdk.File
+	prefixes  []string
+	truncated bool
+	next      listToken
+}
+
+// collect walks the name-ordered file listing after start and gathers up to
+// maxKeys keys and common prefixes.
+func (g *Gateway) collect(r *http.Request, bucket, prefix, delimiter string, start listToken, maxKeys int) (listPage, error) {
+	page := listPage{next: start}
+	if maxKeys == 0 {
+		return page, nil
+	}
+	opts := akavesdk.ListFilesOptions{Prefix: prefix, Limit: akavesdk.MaxListLimit}
+	if start.LastKey != "" {
+		opts.Cursor = akavesdk.CursorAfter(start.LastKey)
+	}
+
+	for {
+		list, err := g.client.ListFiles(r.Context(), bucket, opts)
+		if err != nil {
+			return listPage{}, err
+		}
+		for _, f := range list.Files {
+			if page.next.Prefix != "" && strings.HasPrefix(f.Name, page.next.Prefix) {
+				page.next.LastKey = f.Name
+				continue
+			}
+
+			cp := ""
+			if delimiter != "" {
+				if i := strings.Index(f.Name[len(prefix):], delimiter); i >= 0 {
+					cp = f.Name[:len(prefix)+i+len(delimiter)]
+				}
+			}
+
+			if len(page.files)+len(page.prefixes) >= maxKeys {
+				page.truncated = true
+				return page, nil
+			}
+			if cp != "" {
+				page.prefixes = append(page.prefixes, cp)
+			} else {
+				page.files = append(page.files, f)
+			}
+			page.next = listToken{LastKey: f.Name, Prefix: cp}
+		}
+		if list.NextCursor == "" {
+			return page, nil
+		}
+		opts.Cursor = list.NextCursor
+	}
+}
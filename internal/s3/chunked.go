@@ -0,0 +1,171 @@
+package s3
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxChunkHeaderLength bounds a single aws-chunked header or trailer line.
+const maxChunkHeaderLength = 4096
+
+// chunkedReader decodes an aws-chunked request body:
+//
+//	<hex-size>[;chunk-signature=<sig>]\r\n<data>\r\n ... 0[;chunk-signature=<sig>]\r\n[trailers]\r\n
+//
+// When sig is non-nil every chunk signature is verified against the seed
+// signature of the request. A failed verification is reported before the
+// terminating chunk is consumed, so readers never see io.EOF for a body that
+// does not match its signatures.
+type chunkedReader struct {
+	r   *bufio.Reader
+	sig *Signature
+
+	prevSig   string
+	chunkSig  string
+	remaining int64
+	hash      hash.Hash
+	err       error
+}
+
+func newChunkedReader(r io.Reader, sig *Signature) *chunkedReader {
+	cr := &chunkedReader{r: bufio.NewReader(r), sig: sig, hash: sha256.New()}
+	if sig != nil {
+		cr.prevSig = sig.seed
+	}
+	return cr
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	for cr.remaining == 0 {
+		if err := cr.nextChunk(); err != nil {
+			cr.err = err
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > cr.remaining {
+		p = p[:cr.remaining]
+	}
+	n, err := cr.r.Read(p)
+	cr.hash.Write(p[:n])
+	cr.remaining -= int64(n)
+	if err == io.EOF {
+		err = errIncompleteBody
+	}
+	if err == nil && cr.remaining == 0 {
+		err = cr.endChunk()
+	}
+	if err != nil {
+		cr.err = err
+	}
+	return n, err
+}
+
+// nextChunk reads the next chunk header. It returns io.EOF after the final
+// zero-length chunk and its trailers have been consumed.
+func (cr *chunkedReader) nextChunk() error {
+	line, err := cr.readLine()
+	if err != nil {
+		return err
+	}
+	sizeStr, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeStr), 16, 64)
+	if err != nil || size < 0 {
+		return errIncompleteBody
+	}
+	cr.chunkSig = ""
+	if v, ok := strings.CutPrefix(strings.TrimSpace(ext), "chunk-signature="); ok {
+		cr.chunkSig = v
+	}
+	if cr.sig != nil && cr.chunkSig == "" {
+		return errChunkSignature
+	}
+
+	cr.hash.Reset()
+	cr.remaining = size
+	if size > 0 {
+		return nil
+	}
+
+	if err := cr.verifyChunk(); err != nil {
+		return err
+	}
+	// Trailing headers, e.g. x-amz-checksum-crc32, end with an empty line.
+	for {
+		line, err := cr.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			return io.EOF
+		}
+	}
+}
+
+// endChunk consumes the CRLF following a chunk's data and verifies it.
+func (cr *chunkedReader) endChunk() error {
+	line, err := cr.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return errIncompleteBody
+	}
+	return cr.verifyChunk()
+}
+
+func (cr *chunkedReader) verifyChunk() error {
+	if cr.sig == nil {
+		return nil
+	}
+	expected := cr.sig.chunkSignature(cr.prevSig, hex.EncodeToString(cr.hash.Sum(nil)))
+	if !hmac.Equal([]byte(expected), []byte(cr.chunkSig)) {
+		return errChunkSignature
+	}
+	cr.prevSig = cr.chunkSig
+	return nil
+}
+
+func (cr *chunkedReader) readLine() (string, error) {
+	line, err := cr.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull || len(line) > maxChunkHeaderLength {
+		return "", errIncompleteBody
+	}
+	if err != nil {
+		if err == io.EOF {
+			return "", errIncompleteBody
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+// hashingReader verifies that the body matches a hex SHA-256 digest and
+// fails with errBadDigest instead of returning io.EOF when it does not.
+type hashingReader struct {
+	r    io.Reader
+	hash hash.Hash
+	want string
+}
+
+func newHashingReader(r io.Reader, want string) *hashingReader {
+	return &hashingReader{r: r, hash: sha256.New(), want: want}
+}
+
+func (hr *hashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	hr.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(hr.hash.Sum(nil)) != hr.want {
+		return n, errBadDigest
+	}
+	return n, err
+}
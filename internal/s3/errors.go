@@ -0,0 +1,96 @@
+package s3
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+// Error is an S3 API error rendered as an <Error> XML document.
+type Error struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// withMessage returns a copy of e with a more specific message.
+func (e *Error) withMessage(msg string) *Error {
+	c := *e
+	c.Message = msg
+	return &c
+}
+
+var (
+	errAccessDenied                 = &Error{"AccessDenied", "Access Denied.", http.StatusForbidden}
+	errAuthorizationHeaderMalformed = &Error{"AuthorizationHeaderMalformed", "The authorization header is malformed.", http.StatusBadRequest}
+	errAuthorizationQueryMalformed  = &Error{"AuthorizationQueryParametersError", "The presigned URL query parameters are malformed.", http.StatusBadRequest}
+	errCredentialMalformed          = &Error{"AuthorizationHeaderMalformed", "The credential scope is malformed.", http.StatusBadRequest}
+	errExpiredPresignRequest        = &Error{"AccessDenied", "Request has expired.", http.StatusForbidden}
+	errInvalidAccessKeyID           = &Error{"InvalidAccessKeyId", "The access key ID you provided does not exist in our records.", http.StatusForbidden}
+	errMissingContentSHA256         = &Error{"InvalidRequest", "Missing required header for this request: x-amz-content-sha256.", http.StatusBadRequest}
+	errMissingDate                  = &Error{"AccessDenied", "AWS authentication requires a valid Date or x-amz-date header.", http.StatusForbidden}
+	errRequestNotReadyYet           = &Error{"AccessDenied", "Request is not valid yet.", http.StatusForbidden}
+	errRequestTimeTooSkewed         = &Error{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	errSignatureDoesNotMatch        = &Error{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+	errSignatureVersion             = &Error{"InvalidRequest", "Only AWS Signature Version 4 is supported.", http.StatusBadRequest}
+
+	errChecksumMismatch     = &Error{"BadDigest", "The Content-MD5 or checksum value you specified did not match what was received.", http.StatusBadRequest}
+	errInvalidDigest        = &Error{"InvalidDigest", "The Content-MD5 or checksum value you specified is not valid.", http.StatusBadRequest}
+	errBadDigest            = &Error{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	errChunkSignature       = &Error{"SignatureDoesNotMatch", "The chunk signature does not match.", http.StatusForbidden}
+	errIncompleteBody       = &Error{"IncompleteBody", "The request body is malformed or truncated.", http.StatusBadRequest}
+	errInvalidArgument      = &Error{"InvalidArgument", "Invalid argument.", http.StatusBadRequest}
+	errInvalidBucketName    = &Error{"InvalidBucketName", "The specified bucket is not valid.", http.StatusBadRequest}
+	errInvalidRange         = &Error{"InvalidRange", "The requested range is not satisfiable.", http.StatusRequestedRangeNotSatisfiable}
+	errInternal             = &Error{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+	errMethodNotAllowed     = &Error{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	errNoSuchBucket         = &Error{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	errNoSuchKey            = &Error{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	errBucketAlreadyOwned   = &Error{"BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.", http.StatusConflict}
+	errBucketNotEmpty       = &Error{"BucketNotEmpty", "The bucket you tried to delete is not empty.", http.StatusConflict}
+	errNotImplemented       = &Error{"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	errObjectAlreadyPresent = &Error{"OperationAborted", "An object with this key already exists; delete it before overwriting.", http.StatusConflict}
+	errServiceUnavailable   = &Error{"ServiceUnavailable", "The storage node is unavailable. Please try again.", http.StatusServiceUnavailable}
+)
+
+// errorResponse is the XML body of an S3 error.
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// toS3Error maps an error from the storage layer to the closest S3 error.
+func toS3Error(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	switch akavesdk.KindOf(akavesdk.Classify(err)) {
+	case akavesdk.ErrBucketNotFound:
+		return errNoSuchBucket
+	case akavesdk.ErrFileNotFound:
+		return errNoSuchKey
+	case akavesdk.ErrAlreadyExists:
+		return errObjectAlreadyPresent
+	case akavesdk.ErrBucketNotEmpty:
+		return errBucketNotEmpty
+	case akavesdk.ErrInvalidName:
+		return errInvalidBucketName
+	case akavesdk.ErrInvalidArgument:
+		return errInvalidArgument.withMessage(err.Error())
+	case akavesdk.ErrPermissionDenied:
+		return errAccessDenied
+	case akavesdk.ErrNodeUnavailable:
+		return errServiceUnavailable
+	}
+	return errInternal.withMessage(err.Error())
+}
@@ -0,0 +1,193 @@
+// Package s3 implements an S3-compatible gateway in front of the internal
+// SDK client so that existing S3 tooling (aws-cli, rclone, boto3) can talk
+// to Akave unchanged.
+//
+// Only path-style addressing is supported. Supported operations are
+// ListBuckets, CreateBucket, HeadBucket, DeleteBucket, GetBucketLocation,
+// ListObjects (V1 and V2), PutObject, GetObject, HeadObject and DeleteObject.
+// Every request must be authenticated with AWS Signature Version 4.
+//
+// The gateway is single-tenant: every request is served by the storage it
+// was created with, whatever the access key, and API keys and tenants of
+// the REST API do not apply to it.
+package s3
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/logging"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
+)
+
+// Options configures optional gateway features.
+type Options struct {
+	// Checksums records the digests of uploaded objects and drops them
+	// when objects are deleted. It should be the store used by the REST
+	// API, so both report the same digests. When nil none are recorded.
+	Checksums *checksum.Store
+	// Webhooks receives the bucket and file events of the gateway, the same
+	// ones the REST API emits. When nil no events are sent.
+	Webhooks *webhook.Dispatcher
+}
+
+// Gateway serves the S3 REST API.
+type Gateway struct {
+	client    akavesdk.Storage
+	auth      *Authenticator
+	checksums *checksum.Store
+	webhooks  *webhook.Dispatcher
+}
+
+// New returns a Gateway that stores objects through client and
+// authenticates requests with auth.
+func New(client akavesdk.Storage, auth *Authenticator, opts Options) *Gateway {
+	return &Gateway{client: client, auth: auth, checksums: opts.Checksums, webhooks: opts.Webhooks}
+}
+
+// emit sends ev to the webhook subscriptions. Events carry no tenant, as
+// the gateway serves the default wallet only.
+func (g *Gateway) emit(ev webhook.Event) {
+	if g.webhooks != nil {
+		g.webhooks.Emit(ev)
+	}
+}
+
+// ServeHTTP authenticates the request and dispatches it to the matching
+// S3 operation.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Reuse the ID assigned by the request ID middleware, if any, so the
+	// S3 RequestId matches the server logs.
+	id := logging.RequestID(r.Context())
+	if id == "" {
+		id = newRequestID()
+	}
+	w.Header().Set("x-amz-request-id", id)
+	w.Header().Set("Server", "go-akavelink")
+
+	sig, err := g.auth.Verify(r)
+	if err != nil {
+		writeError(w, r, toS3Error(err))
+		return
+	}
+
+	bucket, key := splitPath(r.URL.Path)
+	q := r.URL.Query()
+
+	switch {
+	case bucket == "":
+		if r.Method != http.MethodGet {
+			writeError(w, r, errMethodNotAllowed)
+			return
+		}
+		g.listBuckets(w, r)
+
+	case key == "":
+		switch r.Method {
+		case http.MethodGet:
+			switch {
+			case q.Has("location"):
+				g.getBucketLocation(w, r, bucket)
+			case unsupportedSubresource(q):
+				writeError(w, r, errNotImplemented)
+			default:
+				g.listObjects(w, r, bucket)
+			}
+		case http.MethodPut:
+			g.createBucket(w, r, bucket)
+		case http.MethodHead:
+			g.headBucket(w, r, bucket)
+		case http.MethodDelete:
+			g.deleteBucket(w, r, bucket)
+		case http.MethodPost:
+			// multi-object delete and other bucket POST operations
+			writeError(w, r, errNotImplemented)
+		default:
+			writeError(w, r, errMethodNotAllowed)
+		}
+
+	default:
+		if unsupportedSubresource(q) {
+			writeError(w, r, errNotImplemented)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			g.putObject(w, r, sig, bucket, key)
+		case http.MethodGet:
+			g.getObject(w, r, bucket, key)
+		case http.MethodHead:
+			g.headObject(w, r, bucket, key)
+		case http.MethodDelete:
+			g.deleteObject(w, r, bucket, key)
+		case http.MethodPost:
+			// multipart uploads
+			writeError(w, r, errNotImplemented)
+		default:
+			writeError(w, r, errMethodNotAllowed)
+		}
+	}
+}
+
+// unsupportedSubresources are query parameters selecting S3 operations the
+// gateway does not implement.
+var unsupportedSubresources = []string{
+	"acl", "cors", "lifecycle", "policy", "tagging", "versioning", "versions",
+	"uploads", "uploadId", "website", "encryption", "notification", "replication",
+	"logging", "object-lock", "retention", "legal-hold", "delete", "attributes",
+}
+
+func unsupportedSubresource(q map[string][]string) bool {
+	for _, k := range unsupportedSubresources {
+		if _, ok := q[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPath splits "/bucket/some/key" into its bucket and key.
+func splitPath(path string) (bucket, key string) {
+	path = strings.TrimPrefix(path, "/")
+	bucket, key, _ = strings.Cut(path, "/")
+	return bucket, key
+}
+
+// writeXML writes v as an XML document with the given status code.
+func writeXML(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("s3: failed to encode response", "error", err)
+	}
+}
+
+// writeError writes e as an S3 <Error> document. HEAD responses carry no body.
+func writeError(w http.ResponseWriter, r *http.Request, e *Error) {
+	if e.StatusCode == http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "s3: request failed", "method", r.Method, "path", r.URL.Path, "error", e.Message)
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(e.StatusCode)
+		return
+	}
+	writeXML(w, e.StatusCode, errorResponse{
+		Code:      e.Code,
+		Message:   e.Message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("x-amz-request-id"),
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return strings.ToUpper(hex.EncodeToString(b))
+}
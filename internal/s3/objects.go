@@ -0,0 +1,203 @@
+package s3
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/httprange"
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
+)
+
+// putObject uploads the request body as a new file. Akave files are
+// immutable, so writing over an existing key fails until it is deleted.
+// The body is checked against Content-MD5 and the other digest headers of
+// the REST API, and the digests of the stored object are recorded.
+func (g *Gateway) putObject(w http.ResponseWriter, r *http.Request, sig *Signature, bucket, key string) {
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		writeError(w, r, errNotImplemented.withMessage("CopyObject is not supported."))
+		return
+	}
+
+	want, err := checksum.ParseExpected(r.Header)
+	if err != nil {
+		writeError(w, r, errInvalidDigest.withMessage(err.Error()))
+		return
+	}
+	body, err := payloadReader(r, sig)
+	if err != nil {
+		writeError(w, r, toS3Error(err))
+		return
+	}
+
+	transfer := metrics.BeginTransfer(metrics.Upload)
+	defer transfer.Done()
+
+	ctx := r.Context()
+	vr := checksum.NewReader(transfer.Reader(body), want)
+	meta, err := g.client.UploadFile(ctx, bucket, key, vr)
+	// Storage backends may not keep the reader's error in the chain.
+	if m := vr.Mismatch(); m != nil {
+		metrics.ChecksumMismatch(metrics.Upload)
+		g.emitFailed(bucket, key, m, errChecksumMismatch)
+		writeError(w, r, errChecksumMismatch)
+		return
+	}
+	if err != nil {
+		e := toS3Error(err)
+		g.emitFailed(bucket, key, err, e)
+		writeError(w, r, e)
+		return
+	}
+
+	digests := vr.Digests()
+	if g.checksums != nil {
+		if err := g.checksums.Put(checksum.FileRef{Bucket: bucket, Name: key}, meta.RootCID, digests); err != nil {
+			slog.ErrorContext(ctx, "s3: failed to record checksums", "bucket", bucket, "key", key, "error", err)
+		}
+	}
+	meta.SHA256, meta.CRC32C = digests.SHA256, digests.CRC32C
+	g.emit(webhook.Event{Type: webhook.FileUploaded, Bucket: bucket, File: key, FileMeta: &meta})
+	w.Header().Set("ETag", meta.ETag())
+	w.WriteHeader(http.StatusOK)
+}
+
+// emitFailed reports an upload that was not stored as a file.failed event
+// whose code is the S3 error code of the response.
+func (g *Gateway) emitFailed(bucket, key string, err error, e *Error) {
+	g.emit(webhook.Event{Type: webhook.FileFailed, Bucket: bucket, File: key, Error: err.Error(), Code: e.Code})
+}
+
+// payloadReader returns a reader over the object data of r, decoding
+// aws-chunked bodies and verifying the payload hash where one was signed.
+func payloadReader(r *http.Request, sig *Signature) (io.Reader, error) {
+	switch h := sig.PayloadHash; h {
+	case unsignedPayload:
+		return r.Body, nil
+	case streamingPayload, streamingPayloadTrailer:
+		return newChunkedReader(r.Body, sig), nil
+	case streamingUnsignedTrail:
+		return newChunkedReader(r.Body, nil), nil
+	default:
+		if len(h) != 64 || strings.Trim(strings.ToLower(h), "0123456789abcdef") != "" {
+			return nil, errInvalidArgument.withMessage("unsupported x-amz-content-sha256 value")
+		}
+		return newHashingReader(r.Body, strings.ToLower(h)), nil
+	}
+}
+
+// getObject streams an object, honouring a single byte range.
+func (g *Gateway) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	ctx := r.Context()
+	meta, ok := g.objectHeaders(w, r, bucket, key)
+	if !ok {
+		return
+	}
+
+	var ranges []httprange.Range
+	if rh := r.Header.Get("Range"); rh != "" && httprange.IfRangeMatches(r, meta.ETag(), meta.ModTime()) {
+		var err error
+		ranges, err = httprange.Parse(rh, meta.Size)
+		if errors.Is(err, httprange.ErrNoOverlap) {
+			w.Header().Set("Content-Range", httprange.Unsatisfied(meta.Size))
+			writeError(w, r, errInvalidRange)
+			return
+		}
+	}
+
+	transfer := metrics.BeginTransfer(metrics.Download)
+	defer transfer.Done()
+
+	// S3 serves at most one range; anything else returns the whole object.
+	if len(ranges) == 1 {
+		ra := ranges[0]
+		w.Header().Set("Content-Range", ra.ContentRange(meta.Size))
+		w.Header().Set("Content-Length", strconv.FormatInt(ra.Length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if err := g.client.DownloadRange(ctx, bucket, key, ra.Start, ra.Length, transfer.Writer(w)); err != nil {
+			slog.ErrorContext(ctx, "s3: range download failed", "bucket", bucket, "key", key, "error", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Length", strconv.FormatInt(meta.Size, 10))
+	w.WriteHeader(http.StatusOK)
+	if err := g.client.DownloadFile(ctx, bucket, key, transfer.Writer(w)); err != nil {
+		slog.ErrorContext(ctx, "s3: download failed", "bucket", bucket, "key", key, "error", err)
+	}
+}
+
+func (g *Gateway) headObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	meta, ok := g.objectHeaders(w, r, bucket, key)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(meta.Size, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// objectHeaders looks up an object, writes its metadata headers and
+// evaluates If-Match / If-None-Match. It reports false once a response
+// has already been written.
+func (g *Gateway) objectHeaders(w http.ResponseWriter, r *http.Request, bucket, key string) (akavesdk.FileMeta, bool) {
+	meta, err := g.client.FileInfo(r.Context(), bucket, key)
+	if err != nil {
+		writeError(w, r, toS3Error(err))
+		return meta, false
+	}
+
+	h := w.Header()
+	h.Set("ETag", meta.ETag())
+	h.Set("Content-Type", "application/octet-stream")
+	h.Set("Accept-Ranges", "bytes")
+	if t := meta.ModTime(); !t.IsZero() {
+		h.Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+
+	if v := r.Header.Get("If-Match"); v != "" && !etagListContains(v, meta.ETag()) {
+		writeError(w, r, &Error{"PreconditionFailed", "At least one of the pre-conditions you specified did not hold.", http.StatusPreconditionFailed})
+		return meta, false
+	}
+	if v := r.Header.Get("If-None-Match"); v != "" && etagListContains(v, meta.ETag()) {
+		w.WriteHeader(http.StatusNotModified)
+		return meta, false
+	}
+	return meta, true
+}
+
+// etagListContains reports whether the comma separated If-Match style list
+// contains etag or the "*" wildcard.
+func etagListContains(list, etag string) bool {
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteObject removes an object. As in S3, deleting a missing key
+// succeeds, but only an object that existed is reported as deleted.
+func (g *Gateway) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	if err := g.client.DeleteFile(r.Context(), bucket, key); err != nil {
+		if e := toS3Error(err); e != errNoSuchKey {
+			writeError(w, r, e)
+			return
+		}
+	} else {
+		g.emit(webhook.Event{Type: webhook.FileDeleted, Bucket: bucket, File: key})
+	}
+	if g.checksums != nil {
+		if err := g.checksums.Delete(checksum.FileRef{Bucket: bucket, Name: key}); err != nil {
+			slog.ErrorContext(r.Context(), "s3: failed to drop checksums", "bucket", bucket, "key", key, "error", err)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/akave-ai/akavesdk/sdk"
+)
+
+// Bucket describes a bucket owned by the client's wallet.
+type Bucket struct {
+	ID        string    `json:"id,omitempty"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// CreateBucket provisions a new bucket under the caller's key and returns its metadata.
+func (c *Client) CreateBucket(ctx context.Context, bucketName string) (_ Bucket, err error) {
+	ctx, done := instrument(ctx, "CreateBucket", bucketName, "")
+	defer done(&err)
+
+	var res *sdk.IPCBucketCreateResult
+	err = c.retry.Do(ctx, "CreateBucket", false, func(ctx context.Context) error {
+		return c.Transact(ctx, func() (err error) {
+			res, err = c.IPC.CreateBucket(ctx, bucketName)
+			return err
+		})
+	})
+	if err != nil {
+		return Bucket{}, Classify(fmt.Errorf("failed to create bucket %q: %w", bucketName, err))
+	}
+	return Bucket{ID: res.ID, Name: res.Name, CreatedAt: res.CreatedAt}, nil
+}
+
+// ViewBucket returns the metadata of a single bucket.
+func (c *Client) ViewBucket(ctx context.Context, bucketName string) (_ Bucket, err error) {
+	ctx, done := instrument(ctx, "ViewBucket", bucketName, "")
+	defer done(&err)
+
+	var b sdk.IPCBucket
+	err = c.retry.Do(ctx, "ViewBucket", true, func(ctx context.Context) (err error) {
+		b, err = c.IPC.ViewBucket(ctx, bucketName)
+		return err
+	})
+	if err != nil {
+		return Bucket{}, Classify(fmt.Errorf("failed to view bucket %q: %w", bucketName, err))
+	}
+	return Bucket{ID: b.ID, Name: b.Name, CreatedAt: b.CreatedAt}, nil
+}
+
+// DeleteBucket removes an empty bucket.
+func (c *Client) DeleteBucket(ctx context.Context, bucketName string) (err error) {
+	ctx, done := instrument(ctx, "DeleteBucket", bucketName, "")
+	defer done(&err)
+
+	err = c.retry.Do(ctx, "DeleteBucket", false, func(ctx context.Context) error {
+		return c.Transact(ctx, func() error {
+			return c.IPC.DeleteBucket(ctx, bucketName)
+		})
+	})
+	c.listings.invalidate(bucketName)
+	if err != nil {
+		return Classify(fmt.Errorf("failed to delete bucket %q: %w", bucketName, err))
+	}
+	return nil
+}
+
+// ListBuckets returns all buckets accessible to this client.
+func (c *Client) ListBuckets(ctx context.Context) (_ []Bucket, err error) {
+	ctx, done := instrument(ctx, "ListBuckets", "", "")
+	defer done(&err)
+
+	var buckets []sdk.IPCBucket
+	err = c.retry.Do(ctx, "ListBuckets", true, func(ctx context.Context) (err error) {
+		buckets, err = c.IPC.ListBuckets(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, Classify(fmt.Errorf("failed to list buckets: %w", err))
+	}
+
+	out := make([]Bucket, len(buckets))
+	for i, b := range buckets {
+		out[i] = Bucket{ID: b.ID, Name: b.Name, CreatedAt: b.CreatedAt}
+	}
+	return out, nil
+}
@@ -0,0 +1,429 @@
+package sdk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Layout of encrypted files.
+//
+// An encrypted file starts with a header of encHeaderSize bytes: the magic
+// "AKVE", the format version, a random 7-byte nonce prefix, the length and
+// bytes of the master key ID, and the length (2 bytes, big endian) and
+// bytes of the wrapped data key, zero padded. The plaintext follows in
+// chunks of encChunkSize bytes, each sealed with AES-256-GCM under the
+// data key. A chunk's nonce is the prefix, its index (4 bytes, big endian)
+// and a byte marking the final chunk; its additional data is the header.
+// Every file ends with a final chunk, which is empty only for an empty
+// file, so truncation and reordering are detected. The fixed sizes let a
+// plaintext offset be mapped to the chunks holding it without reading the
+// file.
+const (
+	encVersion    = 1
+	encHeaderSize = 512
+	encChunkSize  = 64 << 10
+	encTagSize    = 16
+	encSealedSize = encChunkSize + encTagSize
+	encMaxKeyID   = 64
+)
+
+var encMagic = []byte("AKVE")
+
+// errNotEncrypted is returned when a file read through EncryptedStorage
+// does not start with an encryption header.
+var errNotEncrypted = errors.New("file is not encrypted")
+
+// EncryptedStorage encrypts file contents before they reach the wrapped
+// Storage and decrypts them on the way back.
+//
+// Each file gets a random AES-256 data key, wrapped by the KeyWrapper and
+// stored in the file's header, so the master key only ever protects data
+// keys. Sizes reported by FileInfo and ListFiles are plaintext sizes, and
+// range downloads fetch only the chunks covering the range. Bucket and
+// file names are not encrypted. Files written without encryption cannot
+// be read through EncryptedStorage.
+type EncryptedStorage struct {
+	Storage
+	keys KeyWrapper
+}
+
+var _ Storage = (*EncryptedStorage)(nil)
+
+// NewEncryptedStorage returns inner with client-side encryption using data
+// keys wrapped by keys.
+func NewEncryptedStorage(inner Storage, keys KeyWrapper) *EncryptedStorage {
+	return &EncryptedStorage{Storage: inner, keys: keys}
+}
+
+// ListFiles implements Storage.
+func (s *EncryptedStorage) ListFiles(ctx context.Context, bucketName string, opts ListFilesOptions) (FileList, error) {
+	list, err := s.Storage.ListFiles(ctx, bucketName, opts)
+	for i, f := range list.Files {
+		if size, ok := plaintextSize(f.Size); ok {
+			list.Files[i].Size = size
+		}
+	}
+	return list, err
+}
+
+// FileInfo implements Storage.
+func (s *EncryptedStorage) FileInfo(ctx context.Context, bucketName, fileName string) (FileMeta, error) {
+	meta, err := s.Storage.FileInfo(ctx, bucketName, fileName)
+	if err != nil {
+		return FileMeta{}, err
+	}
+	return plaintextMeta(meta), nil
+}
+
+// UploadFile implements Storage.
+func (s *EncryptedStorage) UploadFile(ctx context.Context, bucketName, fileName string, r io.Reader) (FileMeta, error) {
+	header, chunks, err := newEncryptionHeader(ctx, s.keys)
+	if err != nil {
+		return FileMeta{}, fmt.Errorf("failed to encrypt file %q: %w", fileName, err)
+	}
+	meta, err := s.Storage.UploadFile(ctx, bucketName, fileName, &encryptReader{
+		src:    bufio.NewReader(r),
+		chunks: chunks,
+		plain:  make([]byte, encChunkSize),
+		out:    header,
+	})
+	if err != nil {
+		return FileMeta{}, err
+	}
+	return plaintextMeta(meta), nil
+}
+
+// DownloadFile implements Storage.
+func (s *EncryptedStorage) DownloadFile(ctx context.Context, bucketName, fileName string, w io.Writer) error {
+	dw := &decryptWriter{w: w, header: make([]byte, 0, encHeaderSize), open: func(header []byte) (*chunkCipher, error) {
+		return openEncryptionHeader(ctx, s.keys, header)
+	}}
+	if err := s.Storage.DownloadFile(ctx, bucketName, fileName, dw); err != nil {
+		return err
+	}
+	if err := dw.Close(); err != nil {
+		return fmt.Errorf("failed to decrypt file %q in bucket %q: %w", fileName, bucketName, err)
+	}
+	return nil
+}
+
+// DownloadRange implements Storage. It reads the header and then only the
+// chunks overlapping the range.
+func (s *EncryptedStorage) DownloadRange(ctx context.Context, bucketName, fileName string, offset, length int64, w io.Writer) error {
+	if offset < 0 || length < 0 {
+		return invalidArgument("invalid range: offset %d, length %d", offset, length)
+	}
+	if length == 0 {
+		return nil
+	}
+
+	meta, err := s.Storage.FileInfo(ctx, bucketName, fileName)
+	if err != nil {
+		return err
+	}
+	size, ok := plaintextSize(meta.Size)
+	if !ok {
+		return fmt.Errorf("failed to decrypt file %q in bucket %q: %w", fileName, bucketName, errNotEncrypted)
+	}
+	if offset+length > size {
+		return invalidArgument("range exceeds file size: %d bytes missing", offset+length-size)
+	}
+
+	var header bytes.Buffer
+	if err := s.Storage.DownloadRange(ctx, bucketName, fileName, 0, encHeaderSize, &header); err != nil {
+		return err
+	}
+	chunks, err := openEncryptionHeader(ctx, s.keys, header.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to decrypt file %q in bucket %q: %w", fileName, bucketName, err)
+	}
+
+	first, last := offset/encChunkSize, (offset+length-1)/encChunkSize
+	start := encHeaderSize + first*encSealedSize
+	end := min(encHeaderSize+(last+1)*encSealedSize, meta.Size)
+
+	rw := &rangeWriter{w: w, skip: offset - first*encChunkSize, remaining: length}
+	dw := &decryptWriter{w: rw, chunks: chunks, index: uint32(first), partial: true}
+	err = s.Storage.DownloadRange(ctx, bucketName, fileName, start, end-start, dw)
+	if err == nil {
+		err = dw.Close()
+	}
+	// Once the window is complete any error is the abort triggered by
+	// errRangeComplete.
+	if rw.remaining == 0 {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decrypt file %q in bucket %q: %w", fileName, bucketName, err)
+	}
+	return fmt.Errorf("range exceeds file size: %d bytes missing", rw.remaining)
+}
+
+// plaintextSize returns the plaintext size of an encrypted file of n
+// bytes. ok is false when no encrypted file has that size.
+func plaintextSize(n int64) (size int64, ok bool) {
+	body := n - encHeaderSize
+	if body < encTagSize {
+		return 0, false
+	}
+	full, rest := body/encSealedSize, body%encSealedSize
+	switch {
+	case rest == 0:
+		return full * encChunkSize, true
+	case rest < encTagSize:
+		return 0, false
+	}
+	return full*encChunkSize + rest - encTagSize, true
+}
+
+// plaintextMeta replaces the stored size in meta by the plaintext size.
+func plaintextMeta(meta FileMeta) FileMeta {
+	if size, ok := plaintextSize(meta.Size); ok {
+		meta.Size = size
+	}
+	return meta
+}
+
+// chunkCipher seals and opens the chunks of one file.
+type chunkCipher struct {
+	aead   cipher.AEAD
+	header []byte
+	nonce  [12]byte
+}
+
+func newChunkCipher(dataKey, header []byte) (*chunkCipher, error) {
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	c := &chunkCipher{aead: aead, header: header}
+	copy(c.nonce[:7], header[5:12])
+	return c, nil
+}
+
+func (c *chunkCipher) nonceFor(index uint32, final bool) []byte {
+	binary.BigEndian.PutUint32(c.nonce[7:11], index)
+	c.nonce[11] = 0
+	if final {
+		c.nonce[11] = 1
+	}
+	return c.nonce[:]
+}
+
+func (c *chunkCipher) seal(dst, plain []byte, index uint32, final bool) []byte {
+	return c.aead.Seal(dst, c.nonceFor(index, final), plain, c.header)
+}
+
+// open decrypts the sealed chunk at index and reports whether it is the
+// final chunk. Only a full-size chunk can be either.
+func (c *chunkCipher) open(dst, sealed []byte, index uint32) ([]byte, bool, error) {
+	if len(sealed) == encSealedSize {
+		if plain, err := c.aead.Open(dst, c.nonceFor(index, false), sealed, c.header); err == nil {
+			return plain, false, nil
+		}
+	}
+	plain, err := c.aead.Open(dst, c.nonceFor(index, true), sealed, c.header)
+	if err != nil {
+		return nil, false, fmt.Errorf("chunk %d failed authentication", index)
+	}
+	return plain, true, nil
+}
+
+// newEncryptionHeader generates a data key, wraps it and returns the file
+// header together with the cipher of the file's chunks.
+func newEncryptionHeader(ctx context.Context, keys KeyWrapper) ([]byte, *chunkCipher, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	keyID, wrapped, err := keys.Wrap(ctx, dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	if len(keyID) > encMaxKeyID {
+		return nil, nil, fmt.Errorf("master key ID is longer than %d bytes", encMaxKeyID)
+	}
+	if 15+len(keyID)+len(wrapped) > encHeaderSize {
+		return nil, nil, fmt.Errorf("wrapped data key of %d bytes does not fit the header", len(wrapped))
+	}
+
+	header := make([]byte, encHeaderSize)
+	copy(header, encMagic)
+	header[4] = encVersion
+	if _, err := rand.Read(header[5:12]); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate nonce prefix: %w", err)
+	}
+	header[12] = byte(len(keyID))
+	p := 13 + copy(header[13:], keyID)
+	binary.BigEndian.PutUint16(header[p:], uint16(len(wrapped)))
+	copy(header[p+2:], wrapped)
+
+	chunks, err := newChunkCipher(dataKey, header)
+	return header, chunks, err
+}
+
+// openEncryptionHeader unwraps the data key of header and returns the
+// cipher of the file's chunks.
+func openEncryptionHeader(ctx context.Context, keys KeyWrapper, header []byte) (*chunkCipher, error) {
+	if len(header) < encHeaderSize || !bytes.Equal(header[:4], encMagic) {
+		return nil, errNotEncrypted
+	}
+	if header[4] != encVersion {
+		return nil, fmt.Errorf("unsupported encryption format version %d", header[4])
+	}
+	idLen := int(header[12])
+	p := 13 + idLen
+	if idLen > encMaxKeyID {
+		return nil, errors.New("corrupt encryption header")
+	}
+	wrappedLen := int(binary.BigEndian.Uint16(header[p:]))
+	if p+2+wrappedLen > encHeaderSize {
+		return nil, errors.New("corrupt encryption header")
+	}
+	dataKey, err := keys.Unwrap(ctx, string(header[13:p]), header[p+2:p+2+wrappedLen])
+	if err != nil {
+		return nil, err
+	}
+	return newChunkCipher(dataKey, header[:encHeaderSize:encHeaderSize])
+}
+
+// encryptReader reads the header and then the sealed chunks of the
+// plaintext read from src.
+type encryptReader struct {
+	src    *bufio.Reader
+	chunks *chunkCipher
+	plain  []byte
+	sealed []byte
+	out    []byte
+	index  uint32
+	done   bool
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		if err := e.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+// next seals the next chunk. A chunk is final when the plaintext ends
+// within or right after it.
+func (e *encryptReader) next() error {
+	n, err := io.ReadFull(e.src, e.plain)
+	final := false
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		final = true
+	case err != nil:
+		return err
+	default:
+		if _, err := e.src.Peek(1); errors.Is(err, io.EOF) {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+	if !final && e.index == math.MaxUint32 {
+		return errors.New("file is too large to encrypt")
+	}
+	e.sealed = e.chunks.seal(e.sealed[:0], e.plain[:n], e.index, final)
+	e.out = e.sealed
+	e.index++
+	e.done = final
+	return nil
+}
+
+// decryptWriter decrypts the encrypted file written to it and writes the
+// plaintext to w. Only authenticated plaintext is written. The header is
+// read first unless chunks is set; partial writers receive a run of chunks
+// starting at index and do not require the final chunk.
+type decryptWriter struct {
+	w       io.Writer
+	header  []byte
+	open    func(header []byte) (*chunkCipher, error)
+	chunks  *chunkCipher
+	index   uint32
+	partial bool
+
+	sealed []byte
+	plain  []byte
+	final  bool
+}
+
+func (d *decryptWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if d.chunks == nil {
+		need := encHeaderSize - len(d.header)
+		if len(p) < need {
+			d.header = append(d.header, p...)
+			return n, nil
+		}
+		d.header = append(d.header, p[:need]...)
+		p = p[need:]
+		chunks, err := d.open(d.header)
+		if err != nil {
+			return 0, err
+		}
+		d.chunks = chunks
+	}
+	for len(p) > 0 {
+		if d.final {
+			return 0, errors.New("data after the final chunk")
+		}
+		k := min(len(p), encSealedSize-len(d.sealed))
+		d.sealed = append(d.sealed, p[:k]...)
+		p = p[k:]
+		if len(d.sealed) == encSealedSize {
+			if err := d.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// flush decrypts the buffered chunk and writes its plaintext.
+func (d *decryptWriter) flush() error {
+	plain, final, err := d.chunks.open(d.plain[:0], d.sealed, d.index)
+	if err != nil {
+		return err
+	}
+	d.plain = plain
+	d.sealed = d.sealed[:0]
+	d.index++
+	d.final = final
+	_, err = d.w.Write(plain)
+	return err
+}
+
+// Close decrypts the buffered final chunk and reports files that end
+// before their final chunk.
+func (d *decryptWriter) Close() error {
+	if d.chunks == nil {
+		return errNotEncrypted
+	}
+	if len(d.sealed) > 0 {
+		if err := d.flush(); err != nil {
+			return err
+		}
+	}
+	if !d.final && !d.partial {
+		return errors.New("encrypted file is truncated")
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package sdk
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Error kinds returned by Storage implementations. Test for them with
+// errors.Is; the original SDK, gRPC or contract error stays reachable
+// through errors.Unwrap and errors.As.
+var (
+	ErrBucketNotFound    = errors.New("bucket not found")
+	ErrFileNotFound      = errors.New("file not found")
+	ErrAlreadyExists     = errors.New("already exists")
+	ErrBucketNotEmpty    = errors.New("bucket not empty")
+	ErrInvalidName       = errors.New("invalid name")
+	ErrInvalidArgument   = errors.New("invalid argument")
+	ErrPermissionDenied  = errors.New("permission denied")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrNodeUnavailable   = errors.New("node unavailable")
+)
+
+// Error attaches one of the error kinds above to the error that caused it.
+// Its message is the message of the cause.
+type Error struct {
+	Kind error
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap exposes both the kind and the cause to errors.Is and errors.As.
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// contractErrors maps error names reported by the Akave SDK and storage
+// contracts to error kinds. Entries are matched in order, so names that
+// contain another name (BucketInvalidOwner, BucketInvalid) come first.
+var contractErrors = []struct {
+	name string
+	kind error
+}{
+	{"BucketNonexists", ErrBucketNotFound},
+	{"BucketNotFound", ErrBucketNotFound},
+	{"FileNonexists", ErrFileNotFound},
+	{"FileDoesNotExist", ErrFileNotFound},
+	{"FileNotExists", ErrFileNotFound},
+	{"BucketAlreadyExists", ErrAlreadyExists},
+	{"FileAlreadyExists", ErrAlreadyExists},
+	{"FileNameDuplicate", ErrAlreadyExists},
+	{"BucketNonempty", ErrBucketNotEmpty},
+	{"BucketInvalidOwner", ErrPermissionDenied},
+	{"NotBucketOwner", ErrPermissionDenied},
+	{"NotSignedByBucketOwner", ErrPermissionDenied},
+	{"BucketInvalid", ErrInvalidName},
+	{"FileInvalid", ErrInvalidName},
+	{"invalid bucket name", ErrInvalidName},
+	{"empty bucket name", ErrInvalidName},
+	{"empty bucket or file name", ErrInvalidName},
+	{"insufficient funds", ErrInsufficientFunds},
+	{"connection refused", ErrNodeUnavailable},
+	{"no such host", ErrNodeUnavailable},
+	{"connection reset", ErrNodeUnavailable},
+	{"i/o timeout", ErrNodeUnavailable},
+}
+
+// Classify wraps err in an *Error carrying its kind when the kind can be
+// recognised, and returns it unchanged otherwise or when it already has one.
+func Classify(err error) error {
+	if err == nil || KindOf(err) != nil {
+		return err
+	}
+	if kind := classify(err); kind != nil {
+		return &Error{Kind: kind, Err: err}
+	}
+	return err
+}
+
+// KindOf returns the error kind err matches, or nil if it has none.
+func KindOf(err error) error {
+	for _, kind := range []error{
+		ErrBucketNotFound, ErrFileNotFound, ErrAlreadyExists, ErrBucketNotEmpty,
+		ErrInvalidName, ErrInvalidArgument, ErrPermissionDenied,
+		ErrInsufficientFunds, ErrNodeUnavailable,
+	} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	return nil
+}
+
+func classify(err error) error {
+	msg := err.Error()
+	for _, ce := range contractErrors {
+		if strings.Contains(msg, ce.name) {
+			return ce.kind
+		}
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+	switch st.Code() {
+	case codes.NotFound:
+		if strings.Contains(strings.ToLower(st.Message()), "bucket") {
+			return ErrBucketNotFound
+		}
+		return ErrFileNotFound
+	case codes.AlreadyExists:
+		return ErrAlreadyExists
+	case codes.InvalidArgument:
+		return ErrInvalidArgument
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return ErrPermissionDenied
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return ErrNodeUnavailable
+	}
+	return nil
+}
+
+// invalidArgument returns an ErrInvalidArgument error with the given message.
+func invalidArgument(format string, args ...any) error {
+	return &Error{Kind: ErrInvalidArgument, Err: fmt.Errorf(format, args...)}
+}
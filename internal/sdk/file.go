@@ -0,0 +1,363 @@
+package sdk
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akave-ai/akavesdk/sdk"
+)
+
+const (
+	// DefaultListLimit is the page size used when ListFilesOptions.Limit is zero.
+	DefaultListLimit = 100
+	// MaxListLimit caps the page size a caller may request.
+	MaxListLimit = 1000
+)
+
+// FileSortKey selects the field files are ordered by when listing.
+type FileSortKey string
+
+// Supported sort keys for ListFiles.
+const (
+	SortByName      FileSortKey = "name"
+	SortBySize      FileSortKey = "size"
+	SortByCreatedAt FileSortKey = "createdAt"
+)
+
+// ParseFileSortKey validates a user supplied sort key. An empty string selects SortByName.
+// "committedAt" is accepted as an alias for the timestamp reported by the node.
+func ParseFileSortKey(s string) (FileSortKey, error) {
+	switch s {
+	case "", string(SortByName):
+		return SortByName, nil
+	case string(SortBySize):
+		return SortBySize, nil
+	case string(SortByCreatedAt), "committedAt":
+		return SortByCreatedAt, nil
+	default:
+		return "", invalidArgument("unsupported sort key %q", s)
+	}
+}
+
+// File describes a file stored in a bucket.
+type File struct {
+	Name        string    `json:"name"`
+	RootCID     string    `json:"rootCID"`
+	Size        int64     `json:"size"`
+	EncodedSize int64     `json:"encodedSize"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// FileMeta holds the metadata of a single stored file.
+type FileMeta struct {
+	RootCID     string    `json:"rootCID"`
+	BucketName  string    `json:"bucketName"`
+	Name        string    `json:"fileName"`
+	Size        int64     `json:"size"`
+	EncodedSize int64     `json:"encodedSize"`
+	IsPublic    bool      `json:"isPublic"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// CommittedAt is the time the upload was committed, when known. The
+	// SDK reports it for a completed upload but not from FileInfo, so it
+	// is nil for files looked up on the Akave network.
+	CommittedAt *time.Time `json:"committedAt,omitempty"`
+	// SHA256 (hex) and CRC32C (base64) are the digests of the content,
+	// filled in by the HTTP server when it knows them. Storage
+	// implementations leave them empty.
+	SHA256 string `json:"sha256,omitempty"`
+	CRC32C string `json:"crc32c,omitempty"`
+}
+
+// ModTime returns the commit time when known and the creation time
+// otherwise, which is always the case for files from Client.FileInfo.
+func (m FileMeta) ModTime() time.Time {
+	if m.CommittedAt != nil && !m.CommittedAt.IsZero() {
+		return *m.CommittedAt
+	}
+	return m.CreatedAt
+}
+
+// ETag returns a strong HTTP entity tag derived from the file's root CID.
+func (m FileMeta) ETag() string {
+	return `"` + m.RootCID + `"`
+}
+
+// ListFilesOptions controls filtering, ordering and pagination of ListFiles.
+type ListFilesOptions struct {
+	// Prefix restricts results to file names starting with it.
+	Prefix string
+	// Limit is the maximum number of files per page; zero means DefaultListLimit.
+	Limit int
+	// Cursor is the opaque NextCursor of a previous page.
+	Cursor string
+	// SortBy selects the ordering field; names break ties.
+	SortBy FileSortKey
+	// Descending reverses the ordering.
+	Descending bool
+}
+
+// FileList is a single page of a file listing.
+type FileList struct {
+	Files      []File `json:"files"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ListFiles returns one page of the files stored in bucketName.
+//
+// The SDK cannot page: it returns every file of the bucket at once, and the
+// page is cut out of that listing here. To keep paging through a large
+// bucket from fetching the whole listing once per page, the listing fetched
+// for a first page is reused for pages requested with a cursor during the
+// next listingTTL, unless this client changed the bucket meanwhile.
+func (c *Client) ListFiles(ctx context.Context, bucketName string, opts ListFilesOptions) (_ FileList, err error) {
+	ctx, done := instrument(ctx, "ListFiles", bucketName, "")
+	defer done(&err)
+
+	if opts.Cursor != "" {
+		if files, ok := c.listings.get(bucketName); ok {
+			return PaginateFiles(files, opts)
+		}
+	}
+
+	var items []sdk.IPCFileListItem
+	err = c.retry.Do(ctx, "ListFiles", true, func(ctx context.Context) (err error) {
+		items, err = c.IPC.ListFiles(ctx, bucketName)
+		return err
+	})
+	if err != nil {
+		return FileList{}, Classify(fmt.Errorf("failed to list files in bucket %q: %w", bucketName, err))
+	}
+
+	files := make([]File, len(items))
+	for i, it := range items {
+		files[i] = File{
+			Name:        it.Name,
+			RootCID:     it.RootCID,
+			Size:        it.ActualSize,
+			EncodedSize: it.EncodedSize,
+			CreatedAt:   it.CreatedAt,
+		}
+	}
+	c.listings.put(bucketName, files)
+	return PaginateFiles(files, opts)
+}
+
+// FileInfo returns the metadata of a single file. The SDK does not expose
+// the commit time here, so CommittedAt is left nil and FileMeta.ModTime
+// falls back to CreatedAt.
+func (c *Client) FileInfo(ctx context.Context, bucketName, fileName string) (_ FileMeta, err error) {
+	ctx, done := instrument(ctx, "FileInfo", bucketName, fileName)
+	defer done(&err)
+
+	var info sdk.IPCFileMeta
+	err = c.retry.Do(ctx, "FileInfo", true, func(ctx context.Context) (err error) {
+		info, err = c.IPC.FileInfo(ctx, bucketName, fileName)
+		return err
+	})
+	if err != nil {
+		return FileMeta{}, Classify(fmt.Errorf("failed to get info for file %q in bucket %q: %w", fileName, bucketName, err))
+	}
+	return FileMeta{
+		RootCID:     info.RootCID,
+		BucketName:  bucketName,
+		Name:        fileName,
+		Size:        info.ActualSize,
+		EncodedSize: info.EncodedSize,
+		IsPublic:    info.IsPublic,
+		CreatedAt:   info.CreatedAt,
+	}, nil
+}
+
+// DeleteFile removes a file from a bucket.
+func (c *Client) DeleteFile(ctx context.Context, bucketName, fileName string) (err error) {
+	ctx, done := instrument(ctx, "DeleteFile", bucketName, fileName)
+	defer done(&err)
+
+	err = c.retry.Do(ctx, "DeleteFile", false, func(ctx context.Context) error {
+		return c.Transact(ctx, func() error {
+			return c.IPC.FileDelete(ctx, bucketName, fileName)
+		})
+	})
+	c.listings.invalidate(bucketName)
+	if err != nil {
+		return Classify(fmt.Errorf("failed to delete file %q in bucket %q: %w", fileName, bucketName, err))
+	}
+	return nil
+}
+
+// CreateFileUpload opens a new upload session for the given bucket and file name.
+func (c *Client) CreateFileUpload(ctx context.Context, bucket, fileName string) (_ *sdk.IPCFileUpload, err error) {
+	ctx, done := instrument(ctx, "CreateFileUpload", bucket, fileName)
+	defer done(&err)
+
+	var upload *sdk.IPCFileUpload
+	err = c.retry.Do(ctx, "CreateFileUpload", false, func(ctx context.Context) error {
+		return c.Transact(ctx, func() (err error) {
+			upload, err = c.IPC.CreateFileUpload(ctx, bucket, fileName)
+			return err
+		})
+	})
+	return upload, err
+}
+
+// Upload streams the given reader into the established upload session and
+// returns the committed file's metadata. It is not retried, since the
+// reader cannot be replayed.
+func (c *Client) Upload(ctx context.Context, upload *sdk.IPCFileUpload, reader io.Reader) (_ FileMeta, err error) {
+	ctx, done := instrument(ctx, "Upload", upload.BucketName, upload.Name)
+	defer done(&err)
+
+	meta, err := c.IPC.Upload(ctx, upload, reader)
+	c.listings.invalidate(upload.BucketName)
+	if err != nil {
+		return FileMeta{}, Classify(fmt.Errorf("failed to upload file %q: %w", upload.Name, err))
+	}
+	return FileMeta{
+		RootCID:     meta.RootCID,
+		BucketName:  meta.BucketName,
+		Name:        meta.Name,
+		Size:        meta.Size,
+		EncodedSize: meta.EncodedSize,
+		CreatedAt:   meta.CreatedAt,
+		CommittedAt: &meta.CommittedAt,
+	}, nil
+}
+
+// CreateFileDownload opens a download session for the specified bucket and file.
+func (c *Client) CreateFileDownload(ctx context.Context, bucket, fileName string) (_ sdk.IPCFileDownload, err error) {
+	ctx, done := instrument(ctx, "CreateFileDownload", bucket, fileName)
+	defer done(&err)
+
+	var download sdk.IPCFileDownload
+	err = c.retry.Do(ctx, "CreateFileDownload", true, func(ctx context.Context) (err error) {
+		download, err = c.IPC.CreateFileDownload(ctx, bucket, fileName)
+		return err
+	})
+	return download, err
+}
+
+// Download writes the content of the download session to the provided writer.
+func (c *Client) Download(ctx context.Context, download sdk.IPCFileDownload, writer io.Writer) (err error) {
+	ctx, done := instrument(ctx, "Download", download.BucketName, download.Name)
+	defer done(&err)
+
+	return c.IPC.Download(ctx, download, writer)
+}
+
+// fileCursor is the decoded form of FileList.NextCursor. It records the sort
+// position of the last file returned so the next page resumes after it even
+// if files were added or removed in the meantime.
+type fileCursor struct {
+	Name      string    `json:"n"`
+	Size      int64     `json:"s,omitempty"`
+	CreatedAt time.Time `json:"t,omitempty"`
+}
+
+// CursorAfter returns a ListFilesOptions.Cursor that resumes a name-ordered
+// listing with the first file sorting after name.
+func CursorAfter(name string) string {
+	return encodeCursor(File{Name: name})
+}
+
+func encodeCursor(f File) string {
+	b, _ := json.Marshal(fileCursor{Name: f.Name, Size: f.Size, CreatedAt: f.CreatedAt})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (File, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return File{}, invalidArgument("invalid cursor: %w", err)
+	}
+	var c fileCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return File{}, invalidArgument("invalid cursor: %w", err)
+	}
+	return File{Name: c.Name, Size: c.Size, CreatedAt: c.CreatedAt}, nil
+}
+
+// fileLess reports whether a sorts before b for the given key and direction.
+func fileLess(a, b File, key FileSortKey, desc bool) bool {
+	var cmp int
+	switch key {
+	case SortBySize:
+		cmp = compareInt64(a.Size, b.Size)
+	case SortByCreatedAt:
+		cmp = a.CreatedAt.Compare(b.CreatedAt)
+	}
+	if cmp == 0 {
+		cmp = strings.Compare(a.Name, b.Name)
+	}
+	if desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// PaginateFiles filters, sorts and slices files according to opts.
+// It is used by ListFiles and by storage implementations that hold the full
+// listing in memory.
+func PaginateFiles(files []File, opts ListFilesOptions) (FileList, error) {
+	limit := opts.Limit
+	switch {
+	case limit < 0:
+		return FileList{}, invalidArgument("invalid limit %d", limit)
+	case limit == 0:
+		limit = DefaultListLimit
+	case limit > MaxListLimit:
+		limit = MaxListLimit
+	}
+	key := opts.SortBy
+	if key == "" {
+		key = SortByName
+	}
+
+	matched := make([]File, 0, len(files))
+	for _, f := range files {
+		if strings.HasPrefix(f.Name, opts.Prefix) {
+			matched = append(matched, f)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return fileLess(matched[i], matched[j], key, opts.Descending)
+	})
+
+	start := 0
+	if opts.Cursor != "" {
+		pivot, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return FileList{}, err
+		}
+		start = sort.Search(len(matched), func(i int) bool {
+			return fileLess(pivot, matched[i], key, opts.Descending)
+		})
+	}
+
+	end := start + limit
+	if end > len(matched) {
+		end = len(matched)
+	}
+
+	page := FileList{Files: matched[start:end]}
+	if end < len(matched) {
+		page.NextCursor = encodeCursor(matched[end-1])
+	}
+	return page, nil
+}
@@ -0,0 +1,74 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+	"github.com/akave-ai/go-akavelink/internal/tracing"
+)
+
+// errorLabels names the error kinds in metrics.
+var errorLabels = []struct {
+	kind  error
+	label string
+}{
+	{ErrBucketNotFound, "bucket_not_found"},
+	{ErrFileNotFound, "file_not_found"},
+	{ErrAlreadyExists, "already_exists"},
+	{ErrBucketNotEmpty, "bucket_not_empty"},
+	{ErrInvalidName, "invalid_name"},
+	{ErrInvalidArgument, "invalid_argument"},
+	{ErrPermissionDenied, "permission_denied"},
+	{ErrInsufficientFunds, "insufficient_funds"},
+	{ErrNodeUnavailable, "node_unavailable"},
+}
+
+// errorLabel returns the metrics label of err, or "" if err is nil.
+func errorLabel(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+	kind := KindOf(Classify(err))
+	for _, l := range errorLabels {
+		if kind == l.kind {
+			return l.label
+		}
+	}
+	return "internal"
+}
+
+// instrument starts the span of an SDK call and returns the context to
+// make the call with, together with a function ending the span and
+// recording the call's metrics. The function is deferred with a pointer to
+// the call's named error result.
+func instrument(ctx context.Context, operation, bucket, file string, attrs ...attribute.KeyValue) (context.Context, func(*error)) {
+	start := time.Now()
+	if bucket != "" {
+		attrs = append(attrs, attribute.String("akave.bucket", bucket))
+	}
+	if file != "" {
+		attrs = append(attrs, attribute.String("akave.file", file))
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "akave."+operation,
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	return ctx, func(err *error) {
+		kind := errorLabel(*err)
+		metrics.ObserveSDKCall(operation, time.Since(start), kind)
+		if *err != nil {
+			span.SetAttributes(attribute.String("akave.error.kind", kind))
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,200 @@
+package sdk
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// KeyWrapper protects the data keys of encrypted files with a master key
+// that never leaves it.
+type KeyWrapper interface {
+	// Wrap encrypts a data key and returns it together with the ID of the
+	// master key used, which Unwrap is given back.
+	Wrap(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypts a data key wrapped by the master key keyID.
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Keyring is a KeyWrapper holding AES-256 master keys in memory. The first
+// key wraps new data keys; the others only unwrap existing ones, which
+// allows master keys to be rotated without re-encrypting stored files.
+type Keyring struct {
+	ids  []string
+	keys map[string]cipher.AEAD
+}
+
+// LoadKeyFile reads a Keyring from path. Every non-empty line not starting
+// with # holds a 32-byte key, hex or base64 encoded; the first is the
+// current key.
+func LoadKeyFile(path string) (*Keyring, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	var keys [][]byte
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := hex.DecodeString(line)
+		if err != nil {
+			key, err = base64.StdEncoding.DecodeString(line)
+		}
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key file %s line %d: expected a hex or base64 encoded 32-byte key", path, n)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("key file %s holds no keys", path)
+	}
+	return NewKeyring(keys...)
+}
+
+// NewKeyring returns a Keyring of 32-byte master keys; the first is the
+// current key.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("a keyring needs at least one key")
+	}
+	k := &Keyring{keys: make(map[string]cipher.AEAD, len(keys))}
+	for _, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("master keys must be 32 bytes, got %d", len(key))
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(key)
+		id := hex.EncodeToString(sum[:8])
+		k.ids = append(k.ids, id)
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+// Wrap implements KeyWrapper. The wrapped key is the GCM nonce followed by
+// the sealed data key, authenticated together with the key ID.
+func (k *Keyring) Wrap(_ context.Context, dataKey []byte) (string, []byte, error) {
+	id := k.ids[0]
+	aead := k.keys[id]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return id, aead.Seal(nonce, nonce, dataKey, []byte(id)), nil
+}
+
+// Unwrap implements KeyWrapper.
+func (k *Keyring) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %q", keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped data key is truncated")
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, sealed, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with master key %q: %w", keyID, err)
+	}
+	return key, nil
+}
+
+// KeyCommand is a KeyWrapper delegating to an external program, such as
+// a bridge to a KMS or HSM, so the master key never enters this process.
+//
+// The program is started once per operation with the single argument
+// "wrap" or "unwrap". It reads one JSON request from standard input and
+// writes one JSON response to standard output:
+//
+//	wrap:   {"dataKey": "<base64>"}                  -> {"keyId": "...", "wrapped": "<base64>"}
+//	unwrap: {"keyId": "...", "wrapped": "<base64>"}  -> {"dataKey": "<base64>"}
+//
+// A non-zero exit status fails the operation with the program's standard
+// error as the message.
+type KeyCommand struct {
+	// Path is the program to run.
+	Path string
+}
+
+// keyCommandMessage is the request and response of a key command.
+type keyCommandMessage struct {
+	DataKey []byte `json:"dataKey,omitempty"`
+	KeyID   string `json:"keyId,omitempty"`
+	Wrapped []byte `json:"wrapped,omitempty"`
+}
+
+// Wrap implements KeyWrapper.
+func (c KeyCommand) Wrap(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	res, err := c.run(ctx, "wrap", keyCommandMessage{DataKey: dataKey})
+	if err != nil {
+		return "", nil, err
+	}
+	if res.KeyID == "" || len(res.Wrapped) == 0 {
+		return "", nil, fmt.Errorf("key command %s: wrap response lacks keyId or wrapped", c.Path)
+	}
+	return res.KeyID, res.Wrapped, nil
+}
+
+// Unwrap implements KeyWrapper.
+func (c KeyCommand) Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	res, err := c.run(ctx, "unwrap", keyCommandMessage{KeyID: keyID, Wrapped: wrapped})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.DataKey) != 32 {
+		return nil, fmt.Errorf("key command %s: unwrap returned a %d-byte data key", c.Path, len(res.DataKey))
+	}
+	return res.DataKey, nil
+}
+
+func (c KeyCommand) run(ctx context.Context, op string, req keyCommandMessage) (keyCommandMessage, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return keyCommandMessage{}, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Path, op)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return keyCommandMessage{}, fmt.Errorf("key command %s %s failed: %s: %w", c.Path, op, msg, err)
+		}
+		return keyCommandMessage{}, fmt.Errorf("key command %s %s failed: %w", c.Path, op, err)
+	}
+	var res keyCommandMessage
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return keyCommandMessage{}, fmt.Errorf("key command %s %s: invalid response: %w", c.Path, op, err)
+	}
+	return res, nil
+}
+
+// newGCM returns AES-GCM with a 256-bit key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,69 @@
+package sdk
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// listingTTL is how long a bucket listing fetched for a first page is
+	// reused for the pages that follow it.
+	listingTTL = 30 * time.Second
+	// maxListings caps the number of bucket listings kept at once.
+	maxListings = 16
+)
+
+// listingCache keeps recent full bucket listings so that paging through a
+// bucket fetches its listing from the node once rather than once per page.
+// The SDK can only return a bucket's files all at once.
+type listingCache struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	listings map[string]listing
+}
+
+type listing struct {
+	files   []File
+	fetched time.Time
+}
+
+func newListingCache() *listingCache {
+	return &listingCache{now: time.Now, listings: make(map[string]listing)}
+}
+
+// get returns the listing of bucketName fetched within listingTTL.
+func (c *listingCache) get(bucketName string) ([]File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.listings[bucketName]
+	if !ok || c.now().Sub(l.fetched) > listingTTL {
+		delete(c.listings, bucketName)
+		return nil, false
+	}
+	return l.files, true
+}
+
+// put stores the listing of bucketName, evicting the oldest listing when
+// the cache is full.
+func (c *listingCache) put(bucketName string, files []File) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.listings[bucketName]; !ok && len(c.listings) >= maxListings {
+		var oldest string
+		for name, l := range c.listings {
+			if oldest == "" || l.fetched.Before(c.listings[oldest].fetched) {
+				oldest = name
+			}
+		}
+		delete(c.listings, oldest)
+	}
+	c.listings[bucketName] = listing{files: files, fetched: c.now()}
+}
+
+// invalidate drops the listing of bucketName after its files changed.
+func (c *listingCache) invalidate(bucketName string) {
+	c.mu.Lock()
+	delete(c.listings, bucketName)
+	c.mu.Unlock()
+}
@@ -0,0 +1,397 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// minBucketNameLength mirrors the bucket name validation of the Akave SDK.
+const minBucketNameLength = 3
+
+// LocalStorage is a Storage kept entirely on the local machine, either in
+// memory or in a directory. It needs no wallet or network access and is
+// meant for development and tests.
+//
+// Failures carry the same error kinds (ErrBucketNotFound, ErrAlreadyExists,
+// ...) as those of *Client, so callers handle both implementations alike.
+type LocalStorage struct {
+	// dir holds file contents and the metadata index; empty keeps
+	// everything in memory.
+	dir string
+	now func() time.Time
+
+	mu      sync.RWMutex
+	buckets map[string]*localBucket
+	blobs   map[string][]byte
+}
+
+type localBucket struct {
+	Bucket Bucket               `json:"bucket"`
+	Files  map[string]*FileMeta `json:"files"`
+}
+
+var _ Storage = (*LocalStorage)(nil)
+
+// NewMemoryStorage returns an empty LocalStorage held in memory.
+func NewMemoryStorage() *LocalStorage {
+	return &LocalStorage{
+		now:     time.Now,
+		buckets: make(map[string]*localBucket),
+		blobs:   make(map[string][]byte),
+	}
+}
+
+// NewDiskStorage returns a LocalStorage persisted in dir, loading any
+// buckets and files stored there by a previous run.
+func NewDiskStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs"), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	s := NewMemoryStorage()
+	s.dir = dir
+
+	b, err := os.ReadFile(s.indexPath())
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read storage index: %w", err)
+	default:
+		if err := json.Unmarshal(b, &s.buckets); err != nil {
+			return nil, fmt.Errorf("failed to parse storage index: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Close implements Storage. LocalStorage holds no open resources.
+func (s *LocalStorage) Close() error {
+	return nil
+}
+
+// CreateBucket implements Storage.
+func (s *LocalStorage) CreateBucket(ctx context.Context, bucketName string) (Bucket, error) {
+	if len(bucketName) < minBucketNameLength {
+		return Bucket{}, fmt.Errorf("failed to create bucket %q: %w", bucketName, ErrInvalidName)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[bucketName]; ok {
+		return Bucket{}, fmt.Errorf("failed to create bucket %q: %w", bucketName, ErrAlreadyExists)
+	}
+	id, err := randomHex(32)
+	if err != nil {
+		return Bucket{}, err
+	}
+	b := Bucket{ID: id, Name: bucketName, CreatedAt: s.now().UTC().Truncate(time.Second)}
+	s.buckets[bucketName] = &localBucket{Bucket: b, Files: make(map[string]*FileMeta)}
+	return b, s.persist()
+}
+
+// ViewBucket implements Storage.
+func (s *LocalStorage) ViewBucket(ctx context.Context, bucketName string) (Bucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		return Bucket{}, fmt.Errorf("failed to view bucket %q: %w", bucketName, ErrBucketNotFound)
+	}
+	return b.Bucket, nil
+}
+
+// DeleteBucket implements Storage.
+func (s *LocalStorage) DeleteBucket(ctx context.Context, bucketName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		return fmt.Errorf("failed to delete bucket %q: %w", bucketName, ErrBucketNotFound)
+	}
+	if len(b.Files) > 0 {
+		return fmt.Errorf("failed to delete bucket %q: %w", bucketName, ErrBucketNotEmpty)
+	}
+	delete(s.buckets, bucketName)
+	return s.persist()
+}
+
+// ListBuckets implements Storage.
+func (s *LocalStorage) ListBuckets(ctx context.Context) ([]Bucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Bucket, 0, len(s.buckets))
+	for _, b := range s.buckets {
+		out = append(out, b.Bucket)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// ListFiles implements Storage.
+func (s *LocalStorage) ListFiles(ctx context.Context, bucketName string, opts ListFilesOptions) (FileList, error) {
+	s.mu.RLock()
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		s.mu.RUnlock()
+		return FileList{}, fmt.Errorf("failed to list files in bucket %q: %w", bucketName, ErrBucketNotFound)
+	}
+	files := make([]File, 0, len(b.Files))
+	for _, m := range b.Files {
+		files = append(files, File{
+			Name:        m.Name,
+			RootCID:     m.RootCID,
+			Size:        m.Size,
+			EncodedSize: m.EncodedSize,
+			CreatedAt:   m.CreatedAt,
+		})
+	}
+	s.mu.RUnlock()
+
+	return PaginateFiles(files, opts)
+}
+
+// FileInfo implements Storage.
+func (s *LocalStorage) FileInfo(ctx context.Context, bucketName, fileName string) (FileMeta, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	m, err := s.lookup(bucketName, fileName)
+	if err != nil {
+		return FileMeta{}, fmt.Errorf("failed to get info for file %q in bucket %q: %w", fileName, bucketName, err)
+	}
+	return *m, nil
+}
+
+// DeleteFile implements Storage.
+func (s *LocalStorage) DeleteFile(ctx context.Context, bucketName, fileName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.lookup(bucketName, fileName); err != nil {
+		return fmt.Errorf("failed to delete file %q in bucket %q: %w", fileName, bucketName, err)
+	}
+	delete(s.buckets[bucketName].Files, fileName)
+	s.removeBlob(bucketName, fileName)
+	return s.persist()
+}
+
+// UploadFile implements Storage. The content is fully received before the
+// file becomes visible, like a commit on the Akave network.
+func (s *LocalStorage) UploadFile(ctx context.Context, bucketName, fileName string, r io.Reader) (FileMeta, error) {
+	if fileName == "" {
+		return FileMeta{}, fmt.Errorf("failed to create upload: %w", ErrInvalidName)
+	}
+	s.mu.RLock()
+	b, ok := s.buckets[bucketName]
+	_, exists := b.filesOrNil()[fileName]
+	s.mu.RUnlock()
+	if !ok {
+		return FileMeta{}, fmt.Errorf("failed to create upload for file %q in bucket %q: %w", fileName, bucketName, ErrBucketNotFound)
+	}
+	if exists {
+		return FileMeta{}, fmt.Errorf("failed to create upload for file %q in bucket %q: %w", fileName, bucketName, ErrAlreadyExists)
+	}
+
+	created := s.now().UTC()
+	h := sha256.New()
+	var (
+		size int64
+		data []byte
+		err  error
+		tmp  string
+	)
+	if s.dir == "" {
+		data, err = io.ReadAll(io.TeeReader(r, h))
+		size = int64(len(data))
+	} else {
+		tmp, size, err = s.writeTemp(io.TeeReader(r, h))
+	}
+	if err != nil {
+		return FileMeta{}, fmt.Errorf("failed to upload file %q: %w", fileName, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok = s.buckets[bucketName]
+	if !ok {
+		os.Remove(tmp)
+		return FileMeta{}, fmt.Errorf("failed to upload file %q: %w", fileName, ErrBucketNotFound)
+	}
+	if _, exists := b.Files[fileName]; exists {
+		os.Remove(tmp)
+		return FileMeta{}, fmt.Errorf("failed to upload file %q: %w", fileName, ErrAlreadyExists)
+	}
+
+	if s.dir == "" {
+		s.blobs[blobKey(bucketName, fileName)] = data
+	} else if err := os.Rename(tmp, s.blobPath(bucketName, fileName)); err != nil {
+		os.Remove(tmp)
+		return FileMeta{}, fmt.Errorf("failed to upload file %q: %w", fileName, err)
+	}
+
+	committed := s.now().UTC()
+	meta := &FileMeta{
+		RootCID:     "local-" + hex.EncodeToString(h.Sum(nil)),
+		BucketName:  bucketName,
+		Name:        fileName,
+		Size:        size,
+		EncodedSize: size,
+		CreatedAt:   created,
+		CommittedAt: &committed,
+	}
+	b.Files[fileName] = meta
+	return *meta, s.persist()
+}
+
+// DownloadFile implements Storage.
+func (s *LocalStorage) DownloadFile(ctx context.Context, bucketName, fileName string, w io.Writer) error {
+	s.mu.RLock()
+	m, err := s.lookup(bucketName, fileName)
+	size := int64(0)
+	if m != nil {
+		size = m.Size
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to create download for file %q in bucket %q: %w", fileName, bucketName, err)
+	}
+	return s.DownloadRange(ctx, bucketName, fileName, 0, size, w)
+}
+
+// DownloadRange implements Storage.
+func (s *LocalStorage) DownloadRange(ctx context.Context, bucketName, fileName string, offset, length int64, w io.Writer) error {
+	if offset < 0 || length < 0 {
+		return invalidArgument("invalid range: offset %d, length %d", offset, length)
+	}
+
+	s.mu.RLock()
+	m, err := s.lookup(bucketName, fileName)
+	if err != nil {
+		s.mu.RUnlock()
+		return fmt.Errorf("failed to create download for file %q in bucket %q: %w", fileName, bucketName, err)
+	}
+	if offset+length > m.Size {
+		s.mu.RUnlock()
+		return invalidArgument("range exceeds file size: %d bytes missing", offset+length-m.Size)
+	}
+
+	var src io.Reader
+	if s.dir == "" {
+		src = bytes.NewReader(s.blobs[blobKey(bucketName, fileName)][offset : offset+length])
+	} else {
+		f, err := os.Open(s.blobPath(bucketName, fileName))
+		if err != nil {
+			s.mu.RUnlock()
+			return fmt.Errorf("failed to download file %q in bucket %q: %w", fileName, bucketName, err)
+		}
+		defer f.Close()
+		src = io.NewSectionReader(f, offset, length)
+	}
+	s.mu.RUnlock()
+
+	if _, err := io.Copy(w, src); err != nil {
+		return fmt.Errorf("failed to download file %q in bucket %q: %w", fileName, bucketName, err)
+	}
+	return nil
+}
+
+// lookup returns the metadata of a file. The caller must hold s.mu.
+func (s *LocalStorage) lookup(bucketName, fileName string) (*FileMeta, error) {
+	b, ok := s.buckets[bucketName]
+	if !ok {
+		return nil, ErrBucketNotFound
+	}
+	m, ok := b.Files[fileName]
+	if !ok {
+		return nil, ErrFileNotFound
+	}
+	return m, nil
+}
+
+func (b *localBucket) filesOrNil() map[string]*FileMeta {
+	if b == nil {
+		return nil
+	}
+	return b.Files
+}
+
+// persist writes the metadata index when backed by a directory. The caller
+// must hold s.mu for writing.
+func (s *LocalStorage) persist() error {
+	if s.dir == "" {
+		return nil
+	}
+	b, err := json.Marshal(s.buckets)
+	if err != nil {
+		return err
+	}
+	tmp := s.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("failed to write storage index: %w", err)
+	}
+	return os.Rename(tmp, s.indexPath())
+}
+
+// writeTemp streams r into a temporary file inside the storage directory.
+func (s *LocalStorage) writeTemp(r io.Reader) (string, int64, error) {
+	f, err := os.CreateTemp(filepath.Join(s.dir, "blobs"), "upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", 0, err
+	}
+	return f.Name(), n, nil
+}
+
+func (s *LocalStorage) removeBlob(bucketName, fileName string) {
+	if s.dir == "" {
+		delete(s.blobs, blobKey(bucketName, fileName))
+		return
+	}
+	os.Remove(s.blobPath(bucketName, fileName))
+}
+
+func (s *LocalStorage) indexPath() string {
+	return filepath.Join(s.dir, "index.json")
+}
+
+// blobPath names content files by a digest of bucket and file name so that
+// arbitrary file names never reach the file system.
+func (s *LocalStorage) blobPath(bucketName, fileName string) string {
+	sum := sha256.Sum256([]byte(blobKey(bucketName, fileName)))
+	return filepath.Join(s.dir, "blobs", hex.EncodeToString(sum[:]))
+}
+
+func blobKey(bucketName, fileName string) string {
+	return bucketName + "/" + fileName
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,107 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/akave-ai/akavesdk/private/pb"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// NodeProbe checks the availability of an Akave node and the balance of a
+// wallet on its chain. It keeps its own connections so health checks do
+// not compete with transfers.
+type NodeProbe struct {
+	conn      *grpc.ClientConn
+	node      pb.IPCNodeAPIClient
+	wallet    common.Address
+	hasWallet bool
+
+	mu  sync.Mutex
+	eth *ethclient.Client
+}
+
+// NewNodeProbe returns a probe of the node at nodeAddress. privateKeyHex
+// selects the wallet whose balance Balance reports; it may be empty.
+// Connections are established on first use.
+func NewNodeProbe(nodeAddress, privateKeyHex string) (*NodeProbe, error) {
+	p := &NodeProbe{}
+	if privateKeyHex != "" {
+		wallet, err := walletAddress(privateKeyHex)
+		if err != nil {
+			return nil, err
+		}
+		p.wallet = wallet
+		p.hasWallet = true
+	}
+
+	conn, err := grpc.NewClient(nodeAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection to node %q: %w", nodeAddress, err)
+	}
+	p.conn = conn
+	p.node = pb.NewIPCNodeAPIClient(conn)
+	return p, nil
+}
+
+// Ping asks the node for its connection parameters, which succeeds only
+// when the node is reachable and serving.
+func (p *NodeProbe) Ping(ctx context.Context) error {
+	if _, err := p.node.ConnectionParams(ctx, &pb.ConnectionParamsRequest{}); err != nil {
+		return Classify(fmt.Errorf("failed to reach node: %w", err))
+	}
+	return nil
+}
+
+// Balance returns the balance of the probe's wallet in wei.
+func (p *NodeProbe) Balance(ctx context.Context) (*big.Int, error) {
+	if !p.hasWallet {
+		return nil, errors.New("no wallet configured")
+	}
+	eth, err := p.ethClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := eth.BalanceAt(ctx, p.wallet, nil)
+	if err != nil {
+		return nil, Classify(fmt.Errorf("failed to get balance of %s: %w", p.wallet, err))
+	}
+	return balance, nil
+}
+
+// ethClient connects to the chain endpoint advertised by the node.
+func (p *NodeProbe) ethClient(ctx context.Context) (*ethclient.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.eth != nil {
+		return p.eth, nil
+	}
+
+	params, err := p.node.ConnectionParams(ctx, &pb.ConnectionParamsRequest{})
+	if err != nil {
+		return nil, Classify(fmt.Errorf("failed to reach node: %w", err))
+	}
+	eth, err := ethclient.DialContext(ctx, params.GetDialUri())
+	if err != nil {
+		return nil, Classify(fmt.Errorf("failed to connect to chain: %w", err))
+	}
+	p.eth = eth
+	return eth, nil
+}
+
+// Close releases the probe's connections.
+func (p *NodeProbe) Close() error {
+	p.mu.Lock()
+	if p.eth != nil {
+		p.eth.Close()
+		p.eth = nil
+	}
+	p.mu.Unlock()
+	return p.conn.Close()
+}
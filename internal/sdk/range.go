@@ -0,0 +1,129 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/akave-ai/akavesdk/sdk"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// errRangeComplete is returned by rangeWriter once the requested window has
+// been written, aborting the rest of the download.
+var errRangeComplete = errors.New("range complete")
+
+// DownloadRange writes length bytes of the file starting at offset to w.
+//
+// Only the chunks overlapping the requested window are fetched from the
+// network; bytes before offset inside the first chunk are discarded and the
+// download stops as soon as the window has been written.
+func (c *Client) DownloadRange(ctx context.Context, bucketName, fileName string, offset, length int64, w io.Writer) (err error) {
+	ctx, done := instrument(ctx, "DownloadRange", bucketName, fileName,
+		attribute.Int64("akave.range.offset", offset), attribute.Int64("akave.range.length", length))
+	defer done(&err)
+
+	if offset < 0 || length < 0 {
+		return invalidArgument("invalid range: offset %d, length %d", offset, length)
+	}
+	if length == 0 {
+		return nil
+	}
+
+	var full sdk.IPCFileDownload
+	err = c.retry.Do(ctx, "DownloadRange", true, func(ctx context.Context) (err error) {
+		full, err = c.IPC.CreateFileDownload(ctx, bucketName, fileName)
+		return err
+	})
+	if err != nil {
+		return Classify(fmt.Errorf("failed to create download for file %q in bucket %q: %w", fileName, bucketName, err))
+	}
+
+	download := full
+	skip := offset
+	if first, last, chunkStart, ok := chunkSpan(full.Chunks, offset, length); ok {
+		err = c.retry.Do(ctx, "DownloadRange", true, func(ctx context.Context) (err error) {
+			download, err = c.IPC.CreateRangeFileDownload(ctx, bucketName, fileName, first, last+1)
+			return err
+		})
+		if err != nil {
+			return Classify(fmt.Errorf("failed to create range download for file %q in bucket %q: %w", fileName, bucketName, err))
+		}
+		skip = offset - chunkStart
+	}
+
+	rw := &rangeWriter{w: w, skip: skip, remaining: length}
+	// Once the window is complete any error is the abort triggered by
+	// errRangeComplete, however the SDK chose to wrap it.
+	if err := c.IPC.Download(ctx, download, rw); err != nil && rw.remaining > 0 {
+		return Classify(fmt.Errorf("failed to download file %q in bucket %q: %w", fileName, bucketName, err))
+	}
+	if rw.remaining > 0 {
+		return fmt.Errorf("range exceeds file size: %d bytes missing", rw.remaining)
+	}
+	return nil
+}
+
+// chunkSpan returns the indexes of the first and last chunk that overlap
+// [offset, offset+length) and the file offset at which the first chunk
+// begins. ok is false when the chunk sizes are unknown.
+func chunkSpan(chunks []sdk.Chunk, offset, length int64) (first, last, chunkStart int64, ok bool) {
+	end := offset + length
+	first, last = -1, -1
+
+	var pos int64
+	for i, ch := range chunks {
+		if ch.Size <= 0 {
+			return 0, 0, 0, false
+		}
+		next := pos + ch.Size
+		if first < 0 && offset < next {
+			first, chunkStart = int64(i), pos
+		}
+		if end <= next {
+			last = int64(i)
+			break
+		}
+		pos = next
+	}
+	if first < 0 || last < 0 {
+		return 0, 0, 0, false
+	}
+	return first, last, chunkStart, true
+}
+
+// rangeWriter discards the first skip bytes written to it, forwards the
+// following remaining bytes to w and then fails with errRangeComplete.
+type rangeWriter struct {
+	w         io.Writer
+	skip      int64
+	remaining int64
+}
+
+func (rw *rangeWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if rw.skip > 0 {
+		if int64(len(p)) <= rw.skip {
+			rw.skip -= int64(len(p))
+			return n, nil
+		}
+		p = p[rw.skip:]
+		rw.skip = 0
+	}
+	if rw.remaining <= 0 {
+		return 0, errRangeComplete
+	}
+	if int64(len(p)) > rw.remaining {
+		p = p[:rw.remaining]
+	}
+	written, err := rw.w.Write(p)
+	rw.remaining -= int64(written)
+	if err != nil {
+		return 0, err
+	}
+	if rw.remaining == 0 {
+		return n, errRangeComplete
+	}
+	return n, nil
+}
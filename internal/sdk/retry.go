@@ -0,0 +1,139 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+)
+
+// RetryPolicy controls how Client retries calls to the Akave node that
+// failed with a transient error.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first;
+	// values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles with
+	// every further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used when Config.Retry is unset.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 4, InitialBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second}
+}
+
+// nonceConflicts are messages of transactions rejected because another
+// transaction of the same wallet took their nonce. The rejected
+// transaction had no effect, so it can be signed and sent again.
+var nonceConflicts = []string{
+	"nonce too low",
+	"replacement transaction underpriced",
+}
+
+// Retryable reports whether a call that failed with err may be attempted
+// again. Errors proving that the call had no effect, such as nonce
+// conflicts and refused connections, are retryable for every call. Errors
+// leaving the outcome unknown, such as timeouts and reset connections, are
+// retryable only when idempotent is true.
+func Retryable(err error, idempotent bool) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	msg := err.Error()
+	for _, s := range nonceConflicts {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+			return true
+		case codes.DeadlineExceeded:
+			return idempotent
+		}
+		return false
+	}
+
+	switch {
+	case strings.Contains(msg, "connection refused"), strings.Contains(msg, "no such host"):
+		return true
+	case errors.Is(err, context.DeadlineExceeded),
+		strings.Contains(msg, "connection reset"),
+		strings.Contains(msg, "broken pipe"),
+		strings.Contains(msg, "i/o timeout"),
+		strings.Contains(msg, "EOF"):
+		return idempotent
+	}
+	return false
+}
+
+// Do calls call until it succeeds, fails with an error that is not
+// Retryable, or MaxAttempts is reached, and returns the last error.
+//
+// Retries wait for an exponentially growing delay with jitter. They stop
+// early when ctx is done or its deadline would expire before the next
+// attempt could start. Every retry is logged, added to the span in ctx as
+// an event and counted in akavelink_sdk_call_retries_total.
+func (p RetryPolicy) Do(ctx context.Context, operation string, idempotent bool, call func(context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := call(ctx)
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !Retryable(err, idempotent) {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		kind := errorLabel(err)
+		slog.WarnContext(ctx, "retrying Akave SDK call", "operation", operation,
+			"attempt", attempt, "max_attempts", p.MaxAttempts, "delay", delay, "error", err)
+		trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(
+			attribute.Int("akave.retry.attempt", attempt),
+			attribute.String("akave.error.kind", kind),
+			attribute.String("error", err.Error()),
+		))
+		metrics.SDKRetry(operation, kind)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay after the given failed attempt: half of the
+// exponential delay plus a random share of the other half, so that
+// clients failing together do not retry in lockstep.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
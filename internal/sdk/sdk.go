@@ -3,9 +3,12 @@
 package sdk
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/akave-ai/akavesdk/sdk"
+
+	"github.com/akave-ai/go-akavelink/internal/metrics"
 )
 
 // Config defines parameters for initializing the IPC client.
@@ -20,12 +23,18 @@ type Config struct {
 	UseConnectionPool bool
 	// PrivateKeyHex is the hex-encoded private key used for signing transactions.
 	PrivateKeyHex     string
+	// Retry controls retries of calls failing with a transient error; the
+	// zero value selects DefaultRetryPolicy.
+	Retry             RetryPolicy
 }
 
 // Client wraps the AkaveLink IPC API and manages its SDK lifecycle.
 type Client struct {
 	*sdk.IPC
-	core *sdk.SDK
+	core     *sdk.SDK
+	retry    RetryPolicy
+	tx       *TxSequencer
+	listings *listingCache
 }
 
 // NewClient initializes the AkaveLink SDK and returns a configured IPC client.
@@ -34,6 +43,10 @@ func NewClient(cfg Config) (*Client, error) {
 	if cfg.PrivateKeyHex == "" {
 		return nil, fmt.Errorf("configuration error: missing PrivateKeyHex")
 	}
+	tx, err := SequencerFor(cfg.PrivateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("configuration error: %w", err)
+	}
 
 	opts := []sdk.Option{
 		sdk.WithPrivateKey(cfg.PrivateKeyHex),
@@ -56,15 +69,31 @@ func NewClient(cfg Config) (*Client, error) {
 		return nil, fmt.Errorf("failed to obtain IPC interface: %w", err)
 	}
 
-	return &Client{IPC: ipcClient, core: core}, nil
+	retry := cfg.Retry
+	if retry == (RetryPolicy{}) {
+		retry = DefaultRetryPolicy()
+	}
+
+	metrics.SDKClientOpened()
+	return &Client{IPC: ipcClient, core: core, retry: retry, tx: tx, listings: newListingCache()}, nil
 }
 
-// NewIPC returns a fresh IPC interface instance with an updated transaction nonce.
+// NewIPC returns a new IPC session of the client's wallet with its own
+// chain connection. Transactions sent through it bypass the client's
+// TxSequencer; send them through Client methods, or through Transact, to
+// avoid nonce collisions.
 func (c *Client) NewIPC() (*sdk.IPC, error) {
 	return c.core.IPC()
 }
 
+// Transact calls fn once no other transaction of the client's wallet is
+// being sent, see TxSequencer.
+func (c *Client) Transact(ctx context.Context, fn func() error) error {
+	return c.tx.Do(ctx, fn)
+}
+
 // Close terminates all underlying SDK connections.
 func (c *Client) Close() error {
+	metrics.SDKClientClosed()
 	return c.core.Close()
 }
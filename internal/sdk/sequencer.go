@@ -0,0 +1,82 @@
+package sdk
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TxSequencer lets one transaction of a wallet at a time be signed and
+// sent.
+//
+// The Akave SDK picks the nonce of a transaction by asking the chain for
+// the wallet's pending nonce just before sending it, so two transactions
+// built at once get the same nonce and one of them is rejected. Clients
+// of the same wallet therefore share a sequencer, and the calls creating
+// buckets, opening uploads and deleting run through it one at a time.
+// The SDK waits for each transaction to be mined before returning, which
+// keeps the pending nonce current for the next one.
+//
+// The chunk and commit transactions sent while Upload streams a file are
+// not sequenced, since holding the sequencer for a whole upload would
+// block every other write of the wallet; the SDK retries those itself
+// when their nonce is taken.
+type TxSequencer struct {
+	slot chan struct{}
+}
+
+// NewTxSequencer returns a sequencer that is not shared with any client.
+func NewTxSequencer() *TxSequencer {
+	return &TxSequencer{slot: make(chan struct{}, 1)}
+}
+
+// Do waits until no other call of the sequencer runs and then calls fn. It
+// returns ctx's error without calling fn if ctx is done first.
+func (s *TxSequencer) Do(ctx context.Context, fn func() error) error {
+	select {
+	case s.slot <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-s.slot }()
+	return fn()
+}
+
+var (
+	sequencersMu sync.Mutex
+	sequencers   = make(map[common.Address]*TxSequencer)
+)
+
+// SequencerFor returns the sequencer of the wallet of privateKeyHex. Every
+// call for the same wallet returns the same sequencer, so clients that are
+// closed and reopened, such as idle tenant clients, keep coordinating with
+// the clients still open.
+func SequencerFor(privateKeyHex string) (*TxSequencer, error) {
+	wallet, err := walletAddress(privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	sequencersMu.Lock()
+	defer sequencersMu.Unlock()
+	s, ok := sequencers[wallet]
+	if !ok {
+		s = NewTxSequencer()
+		sequencers[wallet] = s
+	}
+	return s, nil
+}
+
+// walletAddress returns the address of the wallet of a hex-encoded private
+// key, with or without the 0x prefix.
+func walletAddress(privateKeyHex string) (common.Address, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(privateKeyHex, "0x"))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid private key: %w", err)
+	}
+	return crypto.PubkeyToAddress(*key.Public().(*ecdsa.PublicKey)), nil
+}
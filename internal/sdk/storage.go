@@ -0,0 +1,60 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Storage is the set of bucket and file operations the HTTP layer needs.
+// It is implemented by *Client against the Akave network and by
+// *LocalStorage for offline development and tests.
+type Storage interface {
+	// CreateBucket provisions a new bucket.
+	CreateBucket(ctx context.Context, bucketName string) (Bucket, error)
+	// ViewBucket returns the metadata of a single bucket.
+	ViewBucket(ctx context.Context, bucketName string) (Bucket, error)
+	// DeleteBucket removes an empty bucket.
+	DeleteBucket(ctx context.Context, bucketName string) error
+	// ListBuckets returns every bucket owned by the caller.
+	ListBuckets(ctx context.Context) ([]Bucket, error)
+
+	// ListFiles returns one page of the files stored in a bucket.
+	ListFiles(ctx context.Context, bucketName string, opts ListFilesOptions) (FileList, error)
+	// FileInfo returns the metadata of a single file.
+	FileInfo(ctx context.Context, bucketName, fileName string) (FileMeta, error)
+	// DeleteFile removes a file from a bucket.
+	DeleteFile(ctx context.Context, bucketName, fileName string) error
+	// UploadFile stores everything read from r as a new file.
+	UploadFile(ctx context.Context, bucketName, fileName string, r io.Reader) (FileMeta, error)
+	// DownloadFile writes the whole content of a file to w.
+	DownloadFile(ctx context.Context, bucketName, fileName string, w io.Writer) error
+	// DownloadRange writes length bytes of a file starting at offset to w.
+	DownloadRange(ctx context.Context, bucketName, fileName string, offset, length int64, w io.Writer) error
+
+	// Close releases the resources held by the storage.
+	Close() error
+}
+
+var _ Storage = (*Client)(nil)
+
+// UploadFile opens an upload session for fileName and streams r into it.
+func (c *Client) UploadFile(ctx context.Context, bucketName, fileName string, r io.Reader) (FileMeta, error) {
+	upload, err := c.CreateFileUpload(ctx, bucketName, fileName)
+	if err != nil {
+		return FileMeta{}, Classify(fmt.Errorf("failed to create upload for file %q in bucket %q: %w", fileName, bucketName, err))
+	}
+	return c.Upload(ctx, upload, r)
+}
+
+// DownloadFile opens a download session for fileName and streams it to w.
+func (c *Client) DownloadFile(ctx context.Context, bucketName, fileName string, w io.Writer) error {
+	download, err := c.CreateFileDownload(ctx, bucketName, fileName)
+	if err != nil {
+		return Classify(fmt.Errorf("failed to create download for file %q in bucket %q: %w", fileName, bucketName, err))
+	}
+	if err := c.Download(ctx, download, w); err != nil {
+		return Classify(fmt.Errorf("failed to download file %q in bucket %q: %w", fileName, bucketName, err))
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/logging"
+	"github.com/akave-ai/go-akavelink/internal/tenant"
+	"github.com/akave-ai/go-akavelink/internal/tus"
+)
+
+// require wraps h so that it only runs for requests carrying an API key
+// with scope, and serves requests of keys assigned to a tenant from that
+// tenant's client. Without a key store every request passes.
+func (s *Server) require(scope auth.Scope, h http.HandlerFunc) http.Handler {
+	return s.authenticate(scope, s.withStorage(h))
+}
+
+// authenticate wraps h so that it only runs for requests carrying an API
+// key with scope. When the route has a {bucket} variable the key must also
+// be allowed to access that bucket.
+func (s *Server) authenticate(scope auth.Scope, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.keys == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		token := auth.TokenFromHeader(r.Header.Get("Authorization"), r.Header.Get("X-API-Key"))
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="akavelink"`)
+			writeError(w, http.StatusUnauthorized, "missing API key")
+			return
+		}
+		key, err := s.keys.Authenticate(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="akavelink", error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if !key.HasScope(scope) {
+			writeError(w, http.StatusForbidden, "API key lacks scope "+string(scope))
+			return
+		}
+		if bucket, ok := mux.Vars(r)["bucket"]; ok && !key.CanAccessBucket(bucket) {
+			writeError(w, http.StatusForbidden, "API key is not allowed to access bucket "+bucket)
+			return
+		}
+
+		logging.Annotate(r.Context(), slog.String("key_id", key.ID), slog.String("tenant", key.Tenant))
+		h.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), key)))
+	})
+}
+
+// withStorage wraps h so that requests authenticated with a key assigned to
+// a tenant are served by that tenant's client, which stays acquired until h
+// returns.
+func (s *Server) withStorage(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, _ := auth.FromContext(r.Context())
+		switch {
+		case key.Tenant != "" && s.tenants != nil:
+			ctx, release, err := s.acquireStorage(r.Context(), key.Tenant)
+			if errors.Is(err, tenant.ErrUnknownTenant) {
+				writeError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			if err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+			defer release()
+			r = r.WithContext(ctx)
+		case key.Tenant != "":
+			writeError(w, http.StatusForbidden, "tenants are not enabled on this server")
+			return
+		case s.client == nil:
+			writeError(w, http.StatusForbidden, "API key is not assigned to a tenant")
+			return
+		}
+		h(w, r)
+	}
+}
+
+// acquireStorage returns ctx serving requests from the client of tenantID,
+// which stays acquired until release is called. Without a tenant ctx keeps
+// the server's default client.
+func (s *Server) acquireStorage(ctx context.Context, tenantID string) (context.Context, func(), error) {
+	if tenantID == "" || s.tenants == nil {
+		return ctx, func() {}, nil
+	}
+	st, release, err := s.tenants.Acquire(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return context.WithValue(ctx, storageKey{}, st), release, nil
+}
+
+// canAccessBucket reports whether the request's API key, if any, may
+// operate on bucket.
+func canAccessBucket(r *http.Request, bucket string) bool {
+	key, ok := auth.FromContext(r.Context())
+	return !ok || key.CanAccessBucket(bucket)
+}
+
+// uploadOwner returns the ID and tenant of the request's API key, if any,
+// which own the resumable uploads it creates.
+func uploadOwner(r *http.Request) (owner, tenant string) {
+	key, _ := auth.FromContext(r.Context())
+	return key.ID, key.Tenant
+}
+
+// canAccessUpload reports whether the request's API key, if any, may query,
+// resume or terminate a resumable upload. The key must belong to the
+// upload's tenant, so the upload is committed with that tenant's storage,
+// and must be the key that created it or an admin key.
+func canAccessUpload(r *http.Request, info tus.Info) bool {
+	return canAccessOwned(r, info.Owner, info.Tenant)
+}
+
+// canAccessOwned reports whether the request's API key, if any, may see
+// something created by the key owner of tenant: it must belong to the same
+// tenant and be the owner or an admin key.
+func canAccessOwned(r *http.Request, owner, tenant string) bool {
+	key, ok := auth.FromContext(r.Context())
+	if !ok {
+		return true
+	}
+	if key.Tenant != tenant {
+		return false
+	}
+	return key.ID == owner || key.HasScope(auth.ScopeAdmin)
+}
+
+// createKeyRequest is the body accepted by createKeyHandler.
+type createKeyRequest struct {
+	Name    string       `json:"name"`
+	Scopes  []auth.Scope `json:"scopes"`
+	Buckets []string     `json:"buckets"`
+	Tenant  string       `json:"tenant"`
+}
+
+// createKeyResponse returns a newly minted key together with its token,
+// which is shown only once.
+type createKeyResponse struct {
+	auth.Key
+	Token string `json:"token"`
+}
+
+// createKeyHandler mints a new API key. Admin keys of a tenant can only
+// mint keys of that tenant, which is the default for the keys they create.
+func (s *Server) createKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req createKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name must not be empty")
+		return
+	}
+	if caller, _ := auth.FromContext(r.Context()); caller.Tenant != "" {
+		if req.Tenant != "" && req.Tenant != caller.Tenant {
+			writeError(w, http.StatusForbidden, "keys of tenant "+caller.Tenant+" cannot create keys of another tenant")
+			return
+		}
+		req.Tenant = caller.Tenant
+	}
+
+	if req.Tenant != "" {
+		if s.tenants == nil {
+			writeError(w, http.StatusBadRequest, "tenants are not enabled on this server")
+			return
+		}
+		if _, err := s.tenants.Registry().Lookup(req.Tenant); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	key, token, err := s.keys.Create(auth.Key{
+		Name:    req.Name,
+		Scopes:  req.Scopes,
+		Buckets: req.Buckets,
+		Tenant:  req.Tenant,
+	})
+	if errors.Is(err, auth.ErrInvalidScope) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, createKeyResponse{Key: key, Token: token})
+}
+
+// listKeysHandler returns the API keys the caller manages, without their
+// secrets: every key for an admin key without a tenant, and the keys of
+// its own tenant otherwise.
+func (s *Server) listKeysHandler(w http.ResponseWriter, r *http.Request) {
+	caller, _ := auth.FromContext(r.Context())
+	keys := s.keys.List()
+	if caller.Tenant != "" {
+		keys = slices.DeleteFunc(keys, func(k auth.Key) bool { return k.Tenant != caller.Tenant })
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// revokeKeyHandler deletes an API key. Keys of other tenants are reported
+// as missing to admin keys of a tenant.
+func (s *Server) revokeKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	key, err := s.keys.Get(id)
+	if caller, _ := auth.FromContext(r.Context()); err == nil && caller.Tenant != "" && key.Tenant != caller.Tenant {
+		err = fmt.Errorf("key %q: %w", id, auth.ErrKeyNotFound)
+	}
+	if err == nil {
+		err = s.keys.Revoke(id)
+	}
+	if errors.Is(err, auth.ErrKeyNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id})
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
+)
+
+// listBucketsHandler returns every bucket owned by the client that the
+// caller's API key may access.
+func (s *Server) listBucketsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	buckets, err := s.storage(ctx).ListBuckets(ctx)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	visible := buckets[:0]
+	for _, b := range buckets {
+		if canAccessBucket(r, b.Name) {
+			visible = append(visible, b)
+		}
+	}
+	writeJSON(w, http.StatusOK, visible)
+}
+
+// viewBucketHandler returns the ID, name and creation time of a bucket.
+func (s *Server) viewBucketHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	bucket, err := s.storage(ctx).ViewBucket(ctx, mux.Vars(r)["bucket"])
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, bucket)
+}
+
+// createBucketHandler provisions a new bucket named by the path.
+func (s *Server) createBucketHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	bucket, err := s.storage(ctx).CreateBucket(ctx, mux.Vars(r)["bucket"])
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	s.emit(ctx, webhook.Event{Type: webhook.BucketCreated, Bucket: bucket.Name})
+	writeJSON(w, http.StatusCreated, bucket)
+}
+
+// deleteBucketHandler removes the bucket named by the path.
+func (s *Server) deleteBucketHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := mux.Vars(r)["bucket"]
+	if err := s.storage(ctx).DeleteBucket(ctx, name); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	if s.cache != nil {
+		key, _ := auth.FromContext(ctx)
+		s.cache.InvalidateBucket(key.Tenant, name)
+	}
+	s.emit(ctx, webhook.Event{Type: webhook.BucketDeleted, Bucket: name})
+	writeJSON(w, http.StatusOK, map[string]string{"name": name})
+}
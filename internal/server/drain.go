@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// drainer tracks requests in flight so shutdown can wait for them, and
+// holds the readiness flag load balancers poll.
+type drainer struct {
+	notReady atomic.Bool
+
+	mu       sync.Mutex
+	inflight int
+	idle     chan struct{} // closed while inflight is zero
+}
+
+func newDrainer() *drainer {
+	d := &drainer{idle: make(chan struct{})}
+	close(d.idle)
+	return d
+}
+
+func (d *drainer) begin() {
+	d.mu.Lock()
+	if d.inflight == 0 {
+		d.idle = make(chan struct{})
+	}
+	d.inflight++
+	d.mu.Unlock()
+}
+
+func (d *drainer) end() {
+	d.mu.Lock()
+	d.inflight--
+	if d.inflight == 0 {
+		close(d.idle)
+	}
+	d.mu.Unlock()
+}
+
+// SetReady marks the server as ready or not ready to receive traffic. A
+// server that is not ready answers /health with 503 but keeps serving
+// every other request, so a load balancer can stop routing to it before
+// the listener is closed.
+func (s *Server) SetReady(ready bool) {
+	s.drain.notReady.Store(!ready)
+}
+
+// Ready reports whether the server accepts new traffic.
+func (s *Server) Ready() bool {
+	return !s.drain.notReady.Load()
+}
+
+// Track counts requests served by h as in flight until they return, so
+// that Wait covers them. Handler applies it to every API route; wrap other
+// handlers sharing the storage client, such as the S3 gateway, with it.
+func (s *Server) Track(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.drain.begin()
+		defer s.drain.end()
+		h.ServeHTTP(w, r)
+	})
+}
+
+// InFlight returns the number of tracked requests being served.
+func (s *Server) InFlight() int {
+	s.drain.mu.Lock()
+	defer s.drain.mu.Unlock()
+	return s.drain.inflight
+}
+
+// Wait blocks until no tracked request is in flight or ctx is done. Unlike
+// http.Server.Shutdown it also covers handlers still running after
+// http.Server.Close, so the storage client is only closed once nothing
+// uses it.
+func (s *Server) Wait(ctx context.Context) error {
+	s.drain.mu.Lock()
+	idle := s.drain.idle
+	s.drain.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
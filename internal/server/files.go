@@ -0,0 +1,373 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/cache"
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/httprange"
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
+)
+
+// listFilesHandler returns a page of files in a bucket.
+//
+// Query parameters: prefix, limit, cursor, sort (name, size, committedAt)
+// and order (asc, desc).
+func (s *Server) listFilesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	sortBy, err := akavesdk.ParseFileSortKey(q.Get("sort"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	opts := akavesdk.ListFilesOptions{
+		Prefix: q.Get("prefix"),
+		Cursor: q.Get("cursor"),
+		SortBy: sortBy,
+	}
+	switch q.Get("order") {
+	case "", "asc":
+	case "desc":
+		opts.Descending = true
+	default:
+		writeError(w, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		opts.Limit = limit
+	}
+
+	files, err := s.storage(ctx).ListFiles(ctx, mux.Vars(r)["bucket"], opts)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, files)
+}
+
+// downloadHandler streams the content of a file to the response.
+//
+// Single and multiple byte ranges are supported through the Range and
+// If-Range headers; only the chunks covering a requested range are fetched.
+func (s *Server) downloadHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	bucketName, fileName := vars["bucket"], vars["file"]
+	ctx := r.Context()
+
+	meta, err := s.fileInfo(ctx, bucketName, fileName)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	setFileHeaders(w, meta)
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	var ranges []httprange.Range
+	if rh := r.Header.Get("Range"); rh != "" && httprange.IfRangeMatches(r, meta.ETag(), meta.ModTime()) {
+		ranges, err = httprange.Parse(rh, meta.Size)
+		switch {
+		case errors.Is(err, httprange.ErrNoOverlap):
+			w.Header().Set("Content-Range", httprange.Unsatisfied(meta.Size))
+			writeError(w, http.StatusRequestedRangeNotSatisfiable, err.Error())
+			return
+		case err != nil, httprange.TotalLength(ranges) > meta.Size:
+			// Malformed or amplifying range sets are ignored and the whole
+			// file is served instead.
+			ranges = nil
+		}
+	}
+
+	// Content comes from storage, or from the disk cache when it holds the
+	// file. A full download fills the cache before it is served; ranges of
+	// an uncached file are fetched from storage without filling it.
+	download := func(w io.Writer) error {
+		return s.download(ctx, bucketName, fileName, meta, w)
+	}
+	downloadRange := func(w io.Writer, ra httprange.Range) error {
+		return s.storage(ctx).DownloadRange(ctx, bucketName, fileName, ra.Start, ra.Length, w)
+	}
+	if s.cache != nil && meta.RootCID != "" {
+		caller, _ := auth.FromContext(ctx)
+		key := cache.Key{Tenant: caller.Tenant, Bucket: bucketName, File: fileName, RootCID: meta.RootCID}
+		var (
+			f      *os.File
+			result cache.Result
+			err    error
+		)
+		if len(ranges) > 0 {
+			f, result = s.cache.Lookup(key)
+		} else {
+			// The fill may outlive the request, so it holds the tenant's
+			// client itself rather than the one acquired for the request.
+			f, result, err = s.cache.Get(ctx, key, meta.Size, func(ctx context.Context, w io.Writer) error {
+				ctx, release, err := s.acquireStorage(ctx, caller.Tenant)
+				if err != nil {
+					return err
+				}
+				defer release()
+				return s.download(ctx, bucketName, fileName, meta, w)
+			})
+		}
+		if err != nil {
+			var mismatch *checksum.MismatchError
+			if errors.As(err, &mismatch) {
+				slog.ErrorContext(ctx, "download failed", "bucket", bucketName, "file", fileName, "error", err)
+				writeError(w, http.StatusBadGateway, "stored content does not match its checksum")
+				return
+			}
+			writeStorageError(w, r, err)
+			return
+		}
+		w.Header().Set(cache.Header, string(result))
+		if f != nil {
+			defer f.Close()
+			download = func(w io.Writer) error {
+				_, err := io.Copy(w, io.NewSectionReader(f, 0, meta.Size))
+				return err
+			}
+			downloadRange = func(w io.Writer, ra httprange.Range) error {
+				_, err := io.Copy(w, io.NewSectionReader(f, ra.Start, ra.Length))
+				return err
+			}
+		}
+	}
+
+	transfer := metrics.BeginTransfer(metrics.Download)
+	defer transfer.Done()
+
+	switch len(ranges) {
+	case 0:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Length", strconv.FormatInt(meta.Size, 10))
+		w.WriteHeader(http.StatusOK)
+		if err := download(transfer.Writer(w)); err != nil {
+			slog.ErrorContext(ctx, "download failed", "bucket", bucketName, "file", fileName, "error", err)
+		}
+
+	case 1:
+		ra := ranges[0]
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Range", ra.ContentRange(meta.Size))
+		w.Header().Set("Content-Length", strconv.FormatInt(ra.Length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if err := downloadRange(transfer.Writer(w), ra); err != nil {
+			slog.ErrorContext(ctx, "range download failed", "bucket", bucketName, "file", fileName,
+				"offset", ra.Start, "length", ra.Length, "error", err)
+		}
+
+	default:
+		mw := multipart.NewWriter(w)
+		w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+		w.WriteHeader(http.StatusPartialContent)
+		for _, ra := range ranges {
+			part, err := mw.CreatePart(ra.MIMEHeader("application/octet-stream", meta.Size))
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to write multipart range", "bucket", bucketName, "file", fileName, "error", err)
+				return
+			}
+			if err := downloadRange(transfer.Writer(part), ra); err != nil {
+				slog.ErrorContext(ctx, "range download failed", "bucket", bucketName, "file", fileName,
+					"offset", ra.Start, "length", ra.Length, "error", err)
+				return
+			}
+		}
+		mw.Close()
+	}
+}
+
+// download writes the content of a file to w. With verification on read
+// and a recorded digest, the last byte is held back until the content
+// matched it; a response left short of its Content-Length makes net/http
+// close the connection, so the client sees a failed download.
+func (s *Server) download(ctx context.Context, bucketName, fileName string, meta akavesdk.FileMeta, w io.Writer) error {
+	if !s.verifyOnRead || meta.SHA256 == "" {
+		return s.storage(ctx).DownloadFile(ctx, bucketName, fileName, w)
+	}
+	vw := checksum.NewWriter(w, meta.SHA256)
+	err := s.storage(ctx).DownloadFile(ctx, bucketName, fileName, vw)
+	if err == nil {
+		err = vw.Close()
+	}
+	var mismatch *checksum.MismatchError
+	if errors.As(err, &mismatch) {
+		metrics.ChecksumMismatch(metrics.Download)
+	}
+	return err
+}
+
+// fileInfoHandler returns the stored metadata of a file.
+func (s *Server) fileInfoHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	meta, err := s.fileInfo(ctx, vars["bucket"], vars["file"])
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	setFileHeaders(w, meta)
+	writeJSON(w, http.StatusOK, meta)
+}
+
+// headFileHandler answers HEAD requests on the download path with the file's
+// length, entity tag and modification time without transferring any content.
+func (s *Server) headFileHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	meta, err := s.fileInfo(ctx, vars["bucket"], vars["file"])
+	if err != nil {
+		status, _ := classifyError(err)
+		w.WriteHeader(status)
+		return
+	}
+	setFileHeaders(w, meta)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(meta.Size, 10))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+}
+
+// fileInfo returns the metadata of a file including its recorded digests.
+func (s *Server) fileInfo(ctx context.Context, bucketName, fileName string) (akavesdk.FileMeta, error) {
+	meta, err := s.storage(ctx).FileInfo(ctx, bucketName, fileName)
+	if err != nil {
+		return akavesdk.FileMeta{}, err
+	}
+	if d, ok := s.checksums.Get(fileRef(ctx, bucketName, fileName), meta.RootCID); ok {
+		meta.SHA256, meta.CRC32C = d.SHA256, d.CRC32C
+	}
+	return meta, nil
+}
+
+// setFileHeaders sets the validators and digests describing meta.
+func setFileHeaders(w http.ResponseWriter, meta akavesdk.FileMeta) {
+	if meta.RootCID != "" {
+		w.Header().Set("ETag", meta.ETag())
+	}
+	if meta.SHA256 != "" {
+		w.Header().Set(checksum.SHA256Header, meta.SHA256)
+		w.Header().Set(checksum.CRC32CHeader, meta.CRC32C)
+	}
+	if t := meta.ModTime(); !t.IsZero() {
+		w.Header().Set("Last-Modified", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+// invalidate drops the cached copies of a file of the tenant serving ctx
+// that was replaced or deleted.
+func (s *Server) invalidate(ctx context.Context, bucketName, fileName string) {
+	if s.cache != nil {
+		key, _ := auth.FromContext(ctx)
+		s.cache.Invalidate(key.Tenant, bucketName, fileName)
+	}
+}
+
+// forget drops everything kept about a deleted file: its cached copies and
+// its recorded digests.
+func (s *Server) forget(ctx context.Context, bucketName, fileName string) {
+	s.invalidate(ctx, bucketName, fileName)
+	if err := s.checksums.Delete(fileRef(ctx, bucketName, fileName)); err != nil {
+		slog.ErrorContext(ctx, "failed to drop checksums", "bucket", bucketName, "file", fileName, "error", err)
+	}
+}
+
+// fileRef identifies a file of the tenant serving ctx in the checksum
+// store.
+func fileRef(ctx context.Context, bucketName, fileName string) checksum.FileRef {
+	key, _ := auth.FromContext(ctx)
+	return checksum.FileRef{Tenant: key.Tenant, Bucket: bucketName, Name: fileName}
+}
+
+// maxBulkDelete caps the number of files accepted by a single bulk delete.
+const maxBulkDelete = 1000
+
+// deleteFileHandler removes a single file.
+func (s *Server) deleteFileHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	vars := mux.Vars(r)
+
+	if err := s.storage(ctx).DeleteFile(ctx, vars["bucket"], vars["file"]); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	s.forget(ctx, vars["bucket"], vars["file"])
+	s.emit(ctx, webhook.Event{Type: webhook.FileDeleted, Bucket: vars["bucket"], File: vars["file"]})
+	writeJSON(w, http.StatusOK, map[string]string{"bucketName": vars["bucket"], "fileName": vars["file"]})
+}
+
+// bulkDeleteRequest is the body accepted by bulkDeleteHandler.
+type bulkDeleteRequest struct {
+	Files []string `json:"files"`
+}
+
+// bulkDeleteResult reports the outcome for one file of a bulk delete.
+type bulkDeleteResult struct {
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
+}
+
+// bulkDeleteHandler removes every listed file and reports a result per file.
+// Individual failures do not abort the remaining deletions.
+func (s *Server) bulkDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	bucketName := mux.Vars(r)["bucket"]
+
+	var req bulkDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Files) == 0 {
+		writeError(w, http.StatusBadRequest, "files must not be empty")
+		return
+	}
+	if len(req.Files) > maxBulkDelete {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("at most %d files can be deleted per request", maxBulkDelete))
+		return
+	}
+
+	results := make([]bulkDeleteResult, len(req.Files))
+	failed := 0
+	for i, name := range req.Files {
+		results[i] = bulkDeleteResult{Name: name, Success: true}
+		if err := s.storage(ctx).DeleteFile(ctx, bucketName, name); err != nil {
+			_, code := classifyError(err)
+			results[i] = bulkDeleteResult{Name: name, Error: err.Error(), Code: code}
+			failed++
+			continue
+		}
+		s.forget(ctx, bucketName, name)
+		s.emit(ctx, webhook.Event{Type: webhook.FileDeleted, Bucket: bucketName, File: name})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"deleted": len(req.Files) - failed,
+		"failed":  failed,
+		"results": results,
+	})
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultReadinessTimeout bounds each readiness check when no timeout is
+// configured.
+const DefaultReadinessTimeout = 5 * time.Second
+
+// NodeChecker reports on the Akave node behind the storage. It is
+// implemented by *sdk.NodeProbe.
+type NodeChecker interface {
+	// Ping checks that the node is reachable and serving.
+	Ping(ctx context.Context) error
+	// Balance returns the balance of the server's wallet in wei.
+	Balance(ctx context.Context) (*big.Int, error)
+}
+
+// ReadinessOptions configures the checks behind /readyz.
+type ReadinessOptions struct {
+	// Node is checked for connectivity when set.
+	Node NodeChecker
+	// MinBalance fails readiness while the wallet balance reported by Node
+	// is below it, in wei. Nil disables the check.
+	MinBalance *big.Int
+	// Timeout bounds each check; zero uses DefaultReadinessTimeout.
+	Timeout time.Duration
+}
+
+// checkResult is the outcome of one readiness check.
+type checkResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// readinessReport is the body of /readyz.
+type readinessReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks"`
+}
+
+// readinessCheck is a named check run by /readyz.
+type readinessCheck struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// readinessChecks returns the checks that apply to the server's setup.
+func (s *Server) readinessChecks() []readinessCheck {
+	checks := []readinessCheck{{"shutdown", func(context.Context) error {
+		if !s.Ready() {
+			return errors.New("server is shutting down")
+		}
+		return nil
+	}}}
+
+	opts := s.readiness
+	if opts.Node != nil {
+		checks = append(checks, readinessCheck{"node", opts.Node.Ping})
+	}
+	if s.client != nil {
+		checks = append(checks, readinessCheck{"storage", func(ctx context.Context) error {
+			_, err := s.client.ListBuckets(ctx)
+			return err
+		}})
+	}
+	if opts.Node != nil && opts.MinBalance != nil {
+		checks = append(checks, readinessCheck{"wallet_balance", func(ctx context.Context) error {
+			balance, err := opts.Node.Balance(ctx)
+			if err != nil {
+				return err
+			}
+			if balance.Cmp(opts.MinBalance) < 0 {
+				return fmt.Errorf("balance %s wei is below the minimum of %s wei", balance, opts.MinBalance)
+			}
+			return nil
+		}})
+	}
+	return checks
+}
+
+// livezHandler reports that the process is alive. It never checks
+// dependencies, so an unreachable node does not get the process restarted.
+func (s *Server) livezHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readyzHandler runs every readiness check concurrently and reports each
+// outcome. It responds 200 when all pass and 503 otherwise.
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	timeout := s.readiness.Timeout
+	if timeout <= 0 {
+		timeout = DefaultReadinessTimeout
+	}
+	checks := s.readinessChecks()
+
+	report := readinessReport{Status: "ready", Checks: make(map[string]checkResult, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.run(ctx)
+			res := checkResult{Status: "ok", DurationMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Status, res.Error = "failed", err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = res
+			if err != nil {
+				report.Status = "not_ready"
+			}
+		}()
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if report.Status != "ready" {
+		status = http.StatusServiceUnavailable
+		slog.WarnContext(r.Context(), "readiness check failed", "checks", report.Checks)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		slog.Error("failed to encode response", "error", err)
+	}
+}
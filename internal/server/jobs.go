@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/jobs"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+// preferAsync reports whether the client asked for the upload to be
+// committed in the background with "Prefer: respond-async" (RFC 7240).
+func preferAsync(r *http.Request) bool {
+	for _, v := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(pref), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// submitJob spools body and answers 202 with the job that commits it in the
+// background. The digests declared in header are checked when the job runs.
+func (s *Server) submitJob(w http.ResponseWriter, r *http.Request, bucketName, fileName string, body io.Reader, header http.Header) {
+	metadata := make(map[string]string)
+	for _, name := range []string{checksum.MD5Header, checksum.SHA256Header, checksum.CRC32CHeader} {
+		if v := header.Get(name); v != "" {
+			metadata[name] = v
+		}
+	}
+	key, _ := auth.FromContext(r.Context())
+	job, err := s.jobs.Submit(jobs.Job{
+		Bucket:   bucketName,
+		FileName: fileName,
+		Owner:    key.ID,
+		Tenant:   key.Tenant,
+		Metadata: metadata,
+	}, body)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.Header().Set("Preference-Applied", "respond-async")
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// runJob commits the spooled body of an upload job and reports the outcome
+// to the webhooks.
+func (s *Server) runJob(ctx context.Context, job jobs.Job, body io.Reader) (akavesdk.FileMeta, error) {
+	ctx = auth.NewContext(ctx, auth.Key{ID: job.Owner, Tenant: job.Tenant})
+	meta, err := s.commitJob(ctx, job, body)
+	// A job interrupted by shutdown runs again after the next start.
+	if ctx.Err() == nil {
+		s.emitUpload(ctx, job.Bucket, job.FileName, meta, err)
+	}
+	return meta, err
+}
+
+// commitJob commits the spooled body of an upload job with the storage of
+// the tenant that submitted it.
+func (s *Server) commitJob(ctx context.Context, job jobs.Job, body io.Reader) (akavesdk.FileMeta, error) {
+	switch {
+	case job.Tenant != "" && s.tenants != nil:
+		st, release, err := s.tenants.Acquire(job.Tenant)
+		if err != nil {
+			return akavesdk.FileMeta{}, err
+		}
+		defer release()
+		ctx = context.WithValue(ctx, storageKey{}, st)
+	case job.Tenant != "":
+		return akavesdk.FileMeta{}, errors.New("tenants are not enabled on this server")
+	case s.client == nil:
+		return akavesdk.FileMeta{}, errors.New("API key is not assigned to a tenant")
+	}
+
+	header := make(http.Header)
+	for name, v := range job.Metadata {
+		header.Set(name, v)
+	}
+	want, err := checksum.ParseExpected(header)
+	if err != nil {
+		return akavesdk.FileMeta{}, err
+	}
+	return s.storeVerified(ctx, job.Bucket, job.FileName, body, want)
+}
+
+// jobHandler returns the state of an upload job. Only the API key that
+// submitted a job, or an admin key of the same tenant, can see it.
+func (s *Server) jobHandler(w http.ResponseWriter, r *http.Request) {
+	job, err := s.jobs.Get(mux.Vars(r)["id"])
+	if err == nil && !canAccessOwned(r, job.Owner, job.Tenant) {
+		err = jobs.ErrNotFound
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/logging"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+// AkaveResponse is the JSON envelope returned by every API endpoint.
+type AkaveResponse struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	// Code is a stable, machine-readable identifier of the failure, such as
+	// BUCKET_NOT_FOUND. Clients should branch on it rather than on Error.
+	Code string `json:"code,omitempty"`
+	// RequestID identifies the failed request in the server logs. It is
+	// the X-Request-ID of the request, or one assigned by the server.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// Machine-readable error codes reported in AkaveResponse.Code.
+const (
+	CodeBucketNotFound    = "BUCKET_NOT_FOUND"
+	CodeFileNotFound      = "FILE_NOT_FOUND"
+	CodeAlreadyExists     = "ALREADY_EXISTS"
+	CodeBucketNotEmpty    = "BUCKET_NOT_EMPTY"
+	CodeInvalidName       = "INVALID_NAME"
+	CodeInvalidArgument   = "INVALID_ARGUMENT"
+	CodePermissionDenied  = "PERMISSION_DENIED"
+	CodeInsufficientFunds = "INSUFFICIENT_FUNDS"
+	CodeNodeUnavailable   = "NODE_UNAVAILABLE"
+	CodeChecksumMismatch  = "CHECKSUM_MISMATCH"
+	CodeInternal          = "INTERNAL_ERROR"
+)
+
+// errorKinds maps the error kinds of the storage layer to their HTTP status
+// and error code.
+var errorKinds = []struct {
+	kind   error
+	status int
+	code   string
+}{
+	{akavesdk.ErrBucketNotFound, http.StatusNotFound, CodeBucketNotFound},
+	{akavesdk.ErrFileNotFound, http.StatusNotFound, CodeFileNotFound},
+	{akavesdk.ErrAlreadyExists, http.StatusConflict, CodeAlreadyExists},
+	{akavesdk.ErrBucketNotEmpty, http.StatusConflict, CodeBucketNotEmpty},
+	{akavesdk.ErrInvalidName, http.StatusBadRequest, CodeInvalidName},
+	{akavesdk.ErrInvalidArgument, http.StatusBadRequest, CodeInvalidArgument},
+	{akavesdk.ErrPermissionDenied, http.StatusForbidden, CodePermissionDenied},
+	{akavesdk.ErrInsufficientFunds, http.StatusPaymentRequired, CodeInsufficientFunds},
+	{akavesdk.ErrNodeUnavailable, http.StatusServiceUnavailable, CodeNodeUnavailable},
+}
+
+// writeJSON writes data wrapped in a successful AkaveResponse.
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(AkaveResponse{Success: true, Data: data}); err != nil {
+		slog.Error("failed to encode response", "error", err)
+	}
+}
+
+// writeError writes msg wrapped in a failed AkaveResponse. The error code is
+// derived from the status, e.g. BAD_REQUEST for 400.
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeFailure(w, code, statusCode(code), msg)
+}
+
+// writeStorageError reports an error returned by the storage layer with the
+// status and code of its kind. Internal errors are added to the access log
+// record of r.
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	status, code := classifyError(err)
+	if status >= http.StatusInternalServerError {
+		logging.Annotate(r.Context(), slog.String("error", err.Error()))
+	}
+	writeFailure(w, status, code, err.Error())
+}
+
+// writeFailure writes a failed AkaveResponse. The request ID is taken from
+// the response header set by the request ID middleware.
+func writeFailure(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	resp := AkaveResponse{Success: false, Error: msg, Code: code, RequestID: w.Header().Get(logging.RequestIDHeader)}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("failed to encode response", "error", err)
+	}
+}
+
+// classifyError returns the HTTP status and error code for err.
+func classifyError(err error) (int, string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge, statusCode(http.StatusRequestEntityTooLarge)
+	}
+	var mismatch *checksum.MismatchError
+	if errors.As(err, &mismatch) {
+		return http.StatusBadRequest, CodeChecksumMismatch
+	}
+	err = akavesdk.Classify(err)
+	for _, k := range errorKinds {
+		if errors.Is(err, k.kind) {
+			return k.status, k.code
+		}
+	}
+	return http.StatusInternalServerError, CodeInternal
+}
+
+// statusCode turns an HTTP status into an error code, e.g. NOT_FOUND.
+func statusCode(status int) string {
+	if status == http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
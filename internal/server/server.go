@@ -0,0 +1,286 @@
+// Package server implements the go-akavelink HTTP API on top of the internal SDK client.
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/cache"
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/jobs"
+	"github.com/akave-ai/go-akavelink/internal/logging"
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/tenant"
+	"github.com/akave-ai/go-akavelink/internal/tracing"
+	"github.com/akave-ai/go-akavelink/internal/tus"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
+)
+
+// tusBasePath is where the tus resumable upload endpoints are mounted.
+const tusBasePath = "/uploads"
+
+// Options configures optional server features.
+type Options struct {
+	// TusDir enables tus resumable uploads under /uploads, staging the
+	// received bytes in this directory until the upload is complete.
+	TusDir string
+	// TusMaxSize caps the size of a resumable upload; zero means unlimited.
+	TusMaxSize int64
+	// TusExpiration is how long an idle resumable upload is kept.
+	TusExpiration time.Duration
+	// JobsDir enables background uploads: requests sent with
+	// "Prefer: respond-async" are spooled to this directory, answered with
+	// 202 and committed by a pool of workers.
+	JobsDir string
+	// JobWorkers is the number of background uploads run at once.
+	JobWorkers int
+	// JobRetention is how long finished background uploads can be queried.
+	JobRetention time.Duration
+	// MaxUploadSize caps the request body of single-request uploads; zero
+	// means unlimited.
+	MaxUploadSize int64
+	// Metrics serves the Prometheus metrics under /metrics without
+	// authentication. Leave it off when they are served on a separate
+	// listener.
+	Metrics bool
+	// Readiness configures the dependency checks of /readyz.
+	Readiness ReadinessOptions
+	// Checksums records the digests of uploaded files. When nil they are
+	// kept in memory.
+	Checksums *checksum.Store
+	// VerifyOnRead checks full downloads against the recorded SHA-256 and
+	// cuts the response short when the content does not match.
+	VerifyOnRead bool
+	// Cache serves downloads from a disk cache of recently downloaded
+	// files. When nil every download is fetched from storage.
+	Cache *cache.Cache
+	// Webhooks notifies the subscribed endpoints of bucket and file
+	// changes and enables the /admin/webhooks API. When nil no events are
+	// sent.
+	Webhooks *webhook.Dispatcher
+	// Keys enables API key authentication. When nil every endpoint is open.
+	Keys *auth.Store
+	// Tenants serves requests of API keys assigned to a tenant from that
+	// tenant's wallet instead of the default client. Requires Keys.
+	Tenants *tenant.Pool
+}
+
+// Server encapsulates dependencies for HTTP handlers.
+type Server struct {
+	client        akavesdk.Storage
+	tus           *tus.Handler
+	jobs          *jobs.Manager
+	keys          *auth.Store
+	tenants       *tenant.Pool
+	maxUploadSize int64
+	drain         *drainer
+	metrics       bool
+	readiness     ReadinessOptions
+	checksums     *checksum.Store
+	verifyOnRead  bool
+	cache         *cache.Cache
+	webhooks      *webhook.Dispatcher
+}
+
+// New returns a Server backed by the given storage. client may be nil when
+// every API key is assigned to a tenant.
+func New(client akavesdk.Storage, opts Options) (*Server, error) {
+	if opts.Tenants != nil && opts.Keys == nil {
+		return nil, fmt.Errorf("tenants require API key authentication")
+	}
+	if client == nil && opts.Tenants == nil {
+		return nil, fmt.Errorf("a storage client is required")
+	}
+	s := &Server{
+		client:        client,
+		keys:          opts.Keys,
+		tenants:       opts.Tenants,
+		maxUploadSize: opts.MaxUploadSize,
+		drain:         newDrainer(),
+		metrics:       opts.Metrics,
+		readiness:     opts.Readiness,
+		checksums:     opts.Checksums,
+		verifyOnRead:  opts.VerifyOnRead,
+		cache:         opts.Cache,
+		webhooks:      opts.Webhooks,
+	}
+	if s.checksums == nil {
+		s.checksums, _ = checksum.OpenStore("")
+	}
+
+	if opts.TusDir != "" {
+		h, err := tus.NewHandler(tus.Config{
+			Dir:        opts.TusDir,
+			BasePath:   tusBasePath,
+			MaxSize:    opts.TusMaxSize,
+			Expiration: opts.TusExpiration,
+			Authorize:  canAccessBucket,
+			Identify:   uploadOwner,
+			CanAccess:  canAccessUpload,
+		}, s.storeFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize resumable uploads: %w", err)
+		}
+		s.tus = h
+	}
+
+	if opts.JobsDir != "" {
+		m, err := jobs.NewManager(jobs.Config{
+			Dir:       opts.JobsDir,
+			Workers:   opts.JobWorkers,
+			Retention: opts.JobRetention,
+			Classify: func(err error) string {
+				_, code := classifyError(err)
+				return code
+			},
+		}, s.runJob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize background uploads: %w", err)
+		}
+		s.jobs = m
+	}
+	return s, nil
+}
+
+// RunMaintenance performs background work, such as running background
+// uploads, delivering webhooks, removing expired resumable uploads and
+// closing idle tenant clients, until ctx is cancelled.
+func (s *Server) RunMaintenance(ctx context.Context) {
+	var wg sync.WaitGroup
+	if s.tus != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.tus.RunJanitor(ctx, 10*time.Minute)
+		}()
+	}
+	if s.jobs != nil {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.jobs.Run(ctx)
+		}()
+		go func() {
+			defer wg.Done()
+			s.jobs.RunJanitor(ctx, 10*time.Minute)
+		}()
+	}
+	if s.webhooks != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.webhooks.Run(ctx)
+		}()
+	}
+	if s.tenants != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.tenants.RunJanitor(ctx, time.Minute)
+		}()
+	}
+	wg.Wait()
+}
+
+// storageKey is the context key of the storage serving a request.
+type storageKey struct{}
+
+// storage returns the storage serving ctx: the tenant client attached by
+// require, or the default client.
+func (s *Server) storage(ctx context.Context) akavesdk.Storage {
+	if st, ok := ctx.Value(storageKey{}).(akavesdk.Storage); ok {
+		return st
+	}
+	return s.client
+}
+
+// Handler builds the router with every API route registered.
+func (s *Server) Handler() http.Handler {
+	r := mux.NewRouter()
+	traced := tracing.Middleware(routeLabel)
+	accessLog := logging.AccessLog(routeLabel)
+	instrument := func(h http.Handler) http.Handler {
+		return traced(accessLog(metrics.Instrument(routeLabel, h)))
+	}
+	r.Use(instrument)
+	r.NotFoundHandler = instrument(http.NotFoundHandler())
+	r.MethodNotAllowedHandler = instrument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+
+	r.HandleFunc("/health", s.healthHandler).Methods(http.MethodGet)
+	r.HandleFunc("/livez", s.livezHandler).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", s.readyzHandler).Methods(http.MethodGet)
+	if s.metrics {
+		r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+	}
+
+	r.Handle("/buckets", s.require(auth.ScopeBucketsRead, s.listBucketsHandler)).Methods(http.MethodGet)
+	r.Handle("/buckets/{bucket}", s.require(auth.ScopeBucketsRead, s.viewBucketHandler)).Methods(http.MethodGet)
+	r.Handle("/buckets/{bucket}", s.require(auth.ScopeBucketsWrite, s.createBucketHandler)).Methods(http.MethodPost)
+	r.Handle("/buckets/{bucket}", s.require(auth.ScopeBucketsWrite, s.deleteBucketHandler)).Methods(http.MethodDelete)
+
+	r.Handle("/buckets/{bucket}/files", s.require(auth.ScopeFilesRead, s.listFilesHandler)).Methods(http.MethodGet)
+	r.Handle("/buckets/{bucket}/delete", s.require(auth.ScopeFilesWrite, s.bulkDeleteHandler)).Methods(http.MethodPost)
+	r.Handle("/buckets/{bucket}/files/{file:.+}/info", s.require(auth.ScopeFilesRead, s.fileInfoHandler)).Methods(http.MethodGet)
+	r.Handle("/buckets/{bucket}/files/{file:.+}/download", s.require(auth.ScopeFilesRead, s.downloadHandler)).Methods(http.MethodGet)
+	r.Handle("/buckets/{bucket}/files/{file:.+}/download", s.require(auth.ScopeFilesRead, s.headFileHandler)).Methods(http.MethodHead)
+	r.Handle("/buckets/{bucket}/files/{file:.+}", s.require(auth.ScopeFilesWrite, s.putFileHandler)).Methods(http.MethodPut)
+	r.Handle("/buckets/{bucket}/files/{file:.+}", s.require(auth.ScopeFilesWrite, s.deleteFileHandler)).Methods(http.MethodDelete)
+
+	r.Handle("/files/upload/{bucket}", s.require(auth.ScopeFilesWrite, s.uploadHandler)).Methods(http.MethodPost)
+	r.Handle("/files/download/{bucket}/{file:.+}", s.require(auth.ScopeFilesRead, s.downloadHandler)).Methods(http.MethodGet)
+	r.Handle("/files/download/{bucket}/{file:.+}", s.require(auth.ScopeFilesRead, s.headFileHandler)).Methods(http.MethodHead)
+
+	if s.tus != nil {
+		r.PathPrefix(tusBasePath).Handler(s.require(auth.ScopeFilesWrite, s.tus.ServeHTTP))
+	}
+
+	if s.jobs != nil {
+		r.Handle("/jobs/{id}", s.authenticate(auth.ScopeFilesWrite, s.jobHandler)).Methods(http.MethodGet)
+	}
+
+	if s.webhooks != nil {
+		r.Handle("/admin/webhooks", s.authenticate(auth.ScopeAdmin, s.listWebhooksHandler)).Methods(http.MethodGet)
+		r.Handle("/admin/webhooks", s.authenticate(auth.ScopeAdmin, s.createWebhookHandler)).Methods(http.MethodPost)
+		r.Handle("/admin/webhooks/{id}", s.authenticate(auth.ScopeAdmin, s.deleteWebhookHandler)).Methods(http.MethodDelete)
+	}
+
+	if s.keys != nil {
+		r.Handle("/admin/keys", s.authenticate(auth.ScopeAdmin, s.listKeysHandler)).Methods(http.MethodGet)
+		r.Handle("/admin/keys", s.authenticate(auth.ScopeAdmin, s.createKeyHandler)).Methods(http.MethodPost)
+		r.Handle("/admin/keys/{id}", s.authenticate(auth.ScopeAdmin, s.revokeKeyHandler)).Methods(http.MethodDelete)
+	}
+
+	return s.Track(logging.RequestIDs(r))
+}
+
+// routeLabel returns the route template matched by r, e.g.
+// "/buckets/{bucket}", so metrics are not labelled with bucket or file names.
+func routeLabel(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
+
+// healthHandler responds with a simple status OK message, or 503 once the
+// server is shutting down.
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("shutting down"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
+)
+
+// uploadHandler stores the multipart "file" field in the bucket named by the
+// path, creating the bucket first if it does not exist yet.
+//
+// The body is read with a streaming multipart reader: the file part is piped
+// straight into the upload session and never buffered in memory or spooled
+// to disk, so fields sent after it are ignored. Digests of the file may be
+// declared in headers of the file part or of the request. With
+// "Prefer: respond-async" and background uploads enabled the file is
+// spooled instead and committed by a job.
+func (s *Server) uploadHandler(w http.ResponseWriter, r *http.Request) {
+	bucketName := mux.Vars(r)["bucket"]
+	s.limitBody(w, r)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "failed to parse form: "+err.Error())
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "file retrieval error: no file field in form")
+			return
+		}
+		if err != nil {
+			if status, _ := classifyError(err); status == http.StatusRequestEntityTooLarge {
+				writeStorageError(w, r, err)
+				return
+			}
+			writeError(w, http.StatusBadRequest, "failed to parse form: "+err.Error())
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		fileName := part.FileName()
+		if fileName == "" {
+			part.Close()
+			writeError(w, http.StatusBadRequest, "file retrieval error: missing file name")
+			return
+		}
+
+		header := r.Header.Clone()
+		for name, values := range part.Header {
+			header[name] = values
+		}
+		want, err := checksum.ParseExpected(header)
+		if err != nil {
+			part.Close()
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if s.jobs != nil && preferAsync(r) {
+			s.submitJob(w, r, bucketName, fileName, part, header)
+			part.Close()
+			return
+		}
+		meta, err := s.storeVerified(r.Context(), bucketName, fileName, part, want)
+		part.Close()
+		s.emitUpload(r.Context(), bucketName, fileName, meta, err)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		setFileHeaders(w, meta)
+		writeJSON(w, http.StatusCreated, meta)
+		return
+	}
+}
+
+// putFileHandler stores the raw request body under the file name in the path,
+// or spools it for a background job like uploadHandler.
+func (s *Server) putFileHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	s.limitBody(w, r)
+
+	want, err := checksum.ParseExpected(r.Header)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if s.jobs != nil && preferAsync(r) {
+		s.submitJob(w, r, vars["bucket"], vars["file"], r.Body, r.Header)
+		return
+	}
+	meta, err := s.storeVerified(r.Context(), vars["bucket"], vars["file"], r.Body, want)
+	s.emitUpload(r.Context(), vars["bucket"], vars["file"], meta, err)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	setFileHeaders(w, meta)
+	writeJSON(w, http.StatusCreated, meta)
+}
+
+// limitBody caps the request body at the configured maximum upload size.
+// Reading past it fails with an *http.MaxBytesError, reported as 413.
+func (s *Server) limitBody(w http.ResponseWriter, r *http.Request) {
+	if s.maxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxUploadSize)
+	}
+}
+
+// storeFile streams body into a new file, creating the bucket if it does
+// not exist yet, and reports the outcome to the webhooks. It commits
+// finished resumable uploads.
+func (s *Server) storeFile(ctx context.Context, bucketName, fileName string, body io.Reader) (akavesdk.FileMeta, error) {
+	meta, err := s.storeVerified(ctx, bucketName, fileName, body, checksum.Expected{})
+	s.emitUpload(ctx, bucketName, fileName, meta, err)
+	return meta, err
+}
+
+// storeVerified is storeFile checking the content against the digests in
+// want. The digests are computed while the body streams; a mismatch fails
+// the upload before the file is committed. The digests of the stored file
+// are recorded and returned in its metadata.
+func (s *Server) storeVerified(ctx context.Context, bucketName, fileName string, body io.Reader, want checksum.Expected) (akavesdk.FileMeta, error) {
+	transfer := metrics.BeginTransfer(metrics.Upload)
+	defer transfer.Done()
+
+	vr := checksum.NewReader(transfer.Reader(body), want)
+	cr := &countingReader{r: vr}
+	meta, err := s.storage(ctx).UploadFile(ctx, bucketName, fileName, cr)
+	// The bucket check happens before any byte is read, so the upload can be
+	// retried with the same body once the bucket exists.
+	if err != nil && cr.n == 0 && errors.Is(err, akavesdk.ErrBucketNotFound) {
+		if _, err := s.storage(ctx).CreateBucket(ctx, bucketName); err != nil {
+			return akavesdk.FileMeta{}, fmt.Errorf("bucket creation failed: %w", err)
+		}
+		s.emit(ctx, webhook.Event{Type: webhook.BucketCreated, Bucket: bucketName})
+		meta, err = s.storage(ctx).UploadFile(ctx, bucketName, fileName, cr)
+	}
+	// Storage backends may not keep the reader's error in the chain.
+	if mismatch := vr.Mismatch(); mismatch != nil {
+		metrics.ChecksumMismatch(metrics.Upload)
+		return akavesdk.FileMeta{}, fmt.Errorf("upload failed: %w", mismatch)
+	}
+	if err != nil {
+		return akavesdk.FileMeta{}, fmt.Errorf("upload failed: %w", err)
+	}
+
+	s.invalidate(ctx, bucketName, fileName)
+	digests := vr.Digests()
+	if err := s.checksums.Put(fileRef(ctx, bucketName, fileName), meta.RootCID, digests); err != nil {
+		slog.ErrorContext(ctx, "failed to record checksums", "bucket", bucketName, "file", fileName, "error", err)
+	}
+	meta.SHA256, meta.CRC32C = digests.SHA256, digests.CRC32C
+	return meta, nil
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
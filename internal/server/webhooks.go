@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
+)
+
+// emit sends ev to the webhook subscriptions, tagged with the tenant of the
+// request's API key.
+func (s *Server) emit(ctx context.Context, ev webhook.Event) {
+	if s.webhooks == nil {
+		return
+	}
+	if key, ok := auth.FromContext(ctx); ok {
+		ev.Tenant = key.Tenant
+	}
+	s.webhooks.Emit(ev)
+}
+
+// emitUpload reports the outcome of an upload as a file.uploaded or
+// file.failed event.
+func (s *Server) emitUpload(ctx context.Context, bucketName, fileName string, meta akavesdk.FileMeta, err error) {
+	if err != nil {
+		_, code := classifyError(err)
+		s.emit(ctx, webhook.Event{Type: webhook.FileFailed, Bucket: bucketName, File: fileName, Error: err.Error(), Code: code})
+		return
+	}
+	s.emit(ctx, webhook.Event{Type: webhook.FileUploaded, Bucket: bucketName, File: fileName, FileMeta: &meta})
+}
+
+// createWebhookRequest is the body accepted by createWebhookHandler.
+type createWebhookRequest struct {
+	URL    string              `json:"url"`
+	Events []webhook.EventType `json:"events"`
+	Secret string              `json:"secret"`
+	Tenant string              `json:"tenant"`
+}
+
+// createWebhookHandler registers a webhook subscription. The response holds
+// the signing secret, which is not shown again. Admin keys of a tenant can
+// only subscribe to the events of that tenant, which is the default for the
+// subscriptions they create.
+func (s *Server) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if caller, _ := auth.FromContext(r.Context()); caller.Tenant != "" {
+		if req.Tenant != "" && req.Tenant != caller.Tenant {
+			writeError(w, http.StatusForbidden, "keys of tenant "+caller.Tenant+" cannot subscribe to another tenant's events")
+			return
+		}
+		req.Tenant = caller.Tenant
+	} else if req.Tenant != "" {
+		if s.tenants == nil {
+			writeError(w, http.StatusBadRequest, "tenants are not enabled on this server")
+			return
+		}
+		if _, err := s.tenants.Registry().Lookup(req.Tenant); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	sub, err := s.webhooks.Subscriptions().Create(webhook.Subscription{URL: req.URL, Events: req.Events, Tenant: req.Tenant, Secret: req.Secret})
+	if errors.Is(err, webhook.ErrInvalidSubscription) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+// listWebhooksHandler returns the webhook subscriptions the caller manages,
+// without their secrets: every subscription for an admin key without a
+// tenant, and those of its own tenant otherwise.
+func (s *Server) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	caller, _ := auth.FromContext(r.Context())
+	subs := s.webhooks.Subscriptions().List()
+	if caller.Tenant != "" {
+		subs = slices.DeleteFunc(subs, func(sub webhook.Subscription) bool { return sub.Tenant != caller.Tenant })
+	}
+	writeJSON(w, http.StatusOK, subs)
+}
+
+// deleteWebhookHandler removes a webhook subscription. Subscriptions of
+// other tenants are reported as missing to admin keys of a tenant.
+func (s *Server) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	sub, err := s.webhooks.Subscriptions().Get(id)
+	if caller, _ := auth.FromContext(r.Context()); err == nil && caller.Tenant != "" && sub.Tenant != caller.Tenant {
+		err = fmt.Errorf("webhook %q: %w", id, webhook.ErrSubscriptionNotFound)
+	}
+	if err == nil {
+		err = s.webhooks.Subscriptions().Delete(id)
+	}
+	if errors.Is(err, webhook.ErrSubscriptionNotFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id})
+}
@@ -0,0 +1,177 @@
+package tenant
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+// Factory opens the storage client of a tenant.
+type Factory func(t Tenant) (akavesdk.Storage, error)
+
+// DefaultIdleTimeout is how long an unused tenant client is kept open when
+// no timeout is configured.
+const DefaultIdleTimeout = 10 * time.Minute
+
+// Pool caches one storage client per tenant. Clients are opened on first
+// use and closed by Evict once unused for longer than the idle timeout.
+type Pool struct {
+	registry    *Registry
+	factory     Factory
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	mu      sync.Mutex
+	clients map[string]*pooled
+}
+
+// pooled is a tenant client together with its usage bookkeeping.
+type pooled struct {
+	storage akavesdk.Storage
+	err     error
+	// ready is closed once storage or err is set.
+	ready    chan struct{}
+	refs     int
+	lastUsed time.Time
+}
+
+// NewPool returns a Pool opening clients for the tenants of registry with
+// factory. A zero idleTimeout uses DefaultIdleTimeout.
+func NewPool(registry *Registry, factory Factory, idleTimeout time.Duration) *Pool {
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	return &Pool{
+		registry:    registry,
+		factory:     factory,
+		idleTimeout: idleTimeout,
+		now:         time.Now,
+		clients:     make(map[string]*pooled),
+	}
+}
+
+// Registry returns the tenants served by the pool.
+func (p *Pool) Registry() *Registry {
+	return p.registry
+}
+
+// Acquire returns the client of tenant id, opening it if needed. The client
+// stays open until release is called, which must happen exactly once.
+func (p *Pool) Acquire(id string) (akavesdk.Storage, func(), error) {
+	t, err := p.registry.Lookup(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	e, ok := p.clients[id]
+	if !ok {
+		e = &pooled{ready: make(chan struct{})}
+		p.clients[id] = e
+	}
+	e.refs++
+	p.mu.Unlock()
+
+	if !ok {
+		// Concurrent callers for the same tenant wait on ready instead of
+		// opening a second client.
+		e.storage, e.err = p.factory(t)
+		if e.err == nil {
+			metrics.TenantClientOpened()
+		} else {
+			p.mu.Lock()
+			delete(p.clients, id)
+			p.mu.Unlock()
+		}
+		close(e.ready)
+	}
+	<-e.ready
+
+	release := func() {
+		p.mu.Lock()
+		e.refs--
+		e.lastUsed = p.now()
+		p.mu.Unlock()
+	}
+	if e.err != nil {
+		release()
+		return nil, nil, e.err
+	}
+	return e.storage, release, nil
+}
+
+// Len returns the number of open tenant clients.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// Evict closes clients that have been unused for longer than the idle
+// timeout and returns how many were closed.
+func (p *Pool) Evict() int {
+	deadline := p.now().Add(-p.idleTimeout)
+
+	p.mu.Lock()
+	var idle []akavesdk.Storage
+	for id, e := range p.clients {
+		select {
+		case <-e.ready:
+		default:
+			continue
+		}
+		if e.refs == 0 && e.err == nil && e.lastUsed.Before(deadline) {
+			idle = append(idle, e.storage)
+			delete(p.clients, id)
+		}
+	}
+	p.mu.Unlock()
+
+	metrics.TenantClientsEvicted(len(idle))
+	for _, st := range idle {
+		if err := st.Close(); err != nil {
+			slog.Warn("tenant: failed to close idle client", "error", err)
+		}
+	}
+	return len(idle)
+}
+
+// RunJanitor evicts idle clients every interval until ctx is cancelled.
+func (p *Pool) RunJanitor(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if n := p.Evict(); n > 0 {
+				slog.Info("tenant: closed idle clients", "count", n)
+			}
+		}
+	}
+}
+
+// Close closes every open client regardless of use.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	clients := p.clients
+	p.clients = make(map[string]*pooled)
+	p.mu.Unlock()
+
+	var firstErr error
+	for _, e := range clients {
+		<-e.ready
+		if e.err != nil {
+			continue
+		}
+		if err := e.storage.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
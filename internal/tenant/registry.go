@@ -0,0 +1,92 @@
+// Package tenant lets several teams share one server while paying from and
+// owning separate wallets. A Registry maps tenant IDs to wallet keys and a
+// Pool keeps one storage client per tenant, created on first use and closed
+// again once idle.
+package tenant
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// ErrUnknownTenant is returned for tenant IDs missing from the registry.
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// Tenant is one wallet owner.
+type Tenant struct {
+	ID string `json:"id"`
+	// PrivateKey is the hex-encoded wallet key.
+	PrivateKey string `json:"privateKey,omitempty"`
+	// PrivateKeyEnv names an environment variable holding the wallet key,
+	// which keeps the key out of the registry file.
+	PrivateKeyEnv string `json:"privateKeyEnv,omitempty"`
+}
+
+// Registry is an immutable set of tenants.
+type Registry struct {
+	tenants map[string]Tenant
+}
+
+// registryFile is the on-disk layout of a registry.
+type registryFile struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+// LoadRegistry reads a JSON registry of the form
+//
+//	{"tenants": [{"id": "team-a", "privateKeyEnv": "TEAM_A_KEY"}]}
+func LoadRegistry(path string) (*Registry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant registry: %w", err)
+	}
+	var f registryFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant registry: %w", err)
+	}
+	return NewRegistry(f.Tenants...)
+}
+
+// NewRegistry validates tenants and returns a Registry holding them. Keys
+// referenced through PrivateKeyEnv are resolved immediately.
+func NewRegistry(tenants ...Tenant) (*Registry, error) {
+	r := &Registry{tenants: make(map[string]Tenant, len(tenants))}
+	for i, t := range tenants {
+		if t.ID == "" {
+			return nil, fmt.Errorf("tenants[%d]: id is required", i)
+		}
+		if _, dup := r.tenants[t.ID]; dup {
+			return nil, fmt.Errorf("tenants[%d]: duplicate id %q", i, t.ID)
+		}
+		if t.PrivateKeyEnv != "" {
+			t.PrivateKey = os.Getenv(t.PrivateKeyEnv)
+		}
+		if t.PrivateKey == "" {
+			return nil, fmt.Errorf("tenant %q: no private key configured", t.ID)
+		}
+		r.tenants[t.ID] = t
+	}
+	return r, nil
+}
+
+// Lookup returns the tenant with the given ID.
+func (r *Registry) Lookup(id string) (Tenant, error) {
+	t, ok := r.tenants[id]
+	if !ok {
+		return Tenant{}, fmt.Errorf("%w %q", ErrUnknownTenant, id)
+	}
+	return t, nil
+}
+
+// IDs returns the sorted IDs of all tenants.
+func (r *Registry) IDs() []string {
+	ids := make([]string, 0, len(r.tenants))
+	for id := range r.tenants {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,121 @@
+// Package tracing configures OpenTelemetry tracing: W3C trace-context
+// propagation, server spans for HTTP requests and OTLP export.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is reported unless OTEL_SERVICE_NAME overrides it.
+const ServiceName = "go-akavelink"
+
+const instrumentationName = "github.com/akave-ai/go-akavelink"
+
+func init() {
+	// Incoming trace context is honoured even when spans are not
+	// exported, so log lines and downstream calls keep the caller's trace.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Tracer returns the tracer of the server's own spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Setup installs the global tracer provider as configured by the standard
+// OpenTelemetry environment variables and returns a function flushing and
+// stopping it.
+//
+// Spans are exported over OTLP when OTEL_TRACES_EXPORTER is "otlp" or,
+// when it is unset, once OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set. OTEL_EXPORTER_OTLP_PROTOCOL
+// selects "http/protobuf" (the default) or "grpc"; the endpoint, headers,
+// sampler (OTEL_TRACES_SAMPLER) and resource attributes are read by the
+// OpenTelemetry SDK itself. Without an exporter Setup leaves the no-op
+// provider in place.
+func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	noop := func(context.Context) error { return nil }
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return noop, nil
+	}
+
+	exporter := os.Getenv("OTEL_TRACES_EXPORTER")
+	if exporter == "" {
+		exporter = "none"
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			exporter = "otlp"
+		}
+	}
+	switch exporter {
+	case "none":
+		return noop, nil
+	case "otlp":
+	default:
+		return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q; use otlp or none", exporter)
+	}
+
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	var exp sdktrace.SpanExporter
+	switch protocol {
+	case "", "http/protobuf":
+		exp, err = otlptracehttp.New(ctx)
+	case "grpc":
+		exp, err = otlptracegrpc.New(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q; use http/protobuf or grpc", protocol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	// Later sources win, so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+	// override the default service name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(ServiceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Middleware returns a middleware starting a server span for each request,
+// continuing the trace of an incoming traceparent header. route names the
+// request's route, e.g. "/buckets/{bucket}", and is used for the span
+// name and the http.route attribute.
+func Middleware(route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		tagged := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(route(r)))
+			next.ServeHTTP(w, r)
+		})
+		return otelhttp.NewHandler(tagged, "",
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method + " " + route(r)
+			}),
+		)
+	}
+}
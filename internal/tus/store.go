@@ -0,0 +1,224 @@
+package tus
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+// errNotFound is returned for unknown, terminated or expired uploads.
+var errNotFound = errors.New("upload not found")
+
+// Info is the persisted state of a single resumable upload.
+type Info struct {
+	ID       string `json:"id"`
+	Bucket   string `json:"bucket"`
+	FileName string `json:"fileName"`
+	// Owner and Tenant identify the client that created the upload.
+	Owner     string            `json:"owner,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+	// Result is set once the staged data has been committed to Akave.
+	Result *akavesdk.FileMeta `json:"result,omitempty"`
+}
+
+// store keeps staged upload data and state on the local disk as a pair of
+// files per upload: <id>.bin with the received bytes and <id>.json with Info.
+type store struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[string]*uploadLock
+}
+
+// uploadLock is the lock of one upload, kept in store.locks while refs
+// operations hold or wait for it.
+type uploadLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newStore(dir string) (*store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return &store{dir: dir, locks: make(map[string]*uploadLock)}, nil
+}
+
+// lock serialises operations on one upload and returns the unlock function.
+// The lock is dropped once no operation holds or waits for it, so locking
+// unknown IDs does not grow the store.
+func (s *store) lock(id string) func() {
+	s.mu.Lock()
+	l, ok := s.locks[id]
+	if !ok {
+		l = &uploadLock{}
+		s.locks[id] = l
+	}
+	l.refs++
+	s.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(s.locks, id)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *store) dataPath(id string) string { return filepath.Join(s.dir, id+".bin") }
+func (s *store) infoPath(id string) string { return filepath.Join(s.dir, id+".json") }
+
+// create allocates a new upload with an empty data file.
+func (s *store) create(info Info) (Info, error) {
+	id, err := newID()
+	if err != nil {
+		return Info{}, err
+	}
+	info.ID = id
+
+	f, err := os.OpenFile(s.dataPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return Info{}, fmt.Errorf("failed to create staging file: %w", err)
+	}
+	f.Close()
+
+	if err := s.save(info); err != nil {
+		os.Remove(s.dataPath(id))
+		return Info{}, err
+	}
+	return info, nil
+}
+
+// get loads the state of an upload. Expired uploads are reported as missing.
+func (s *store) get(id string, now time.Time) (Info, error) {
+	if !validID(id) {
+		return Info{}, errNotFound
+	}
+	b, err := os.ReadFile(s.infoPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return Info{}, errNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	if err := json.Unmarshal(b, &info); err != nil {
+		return Info{}, fmt.Errorf("corrupt upload state: %w", err)
+	}
+	if now.After(info.ExpiresAt) {
+		return Info{}, errNotFound
+	}
+	return info, nil
+}
+
+// save atomically persists info.
+func (s *store) save(info Info) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	tmp := s.infoPath(info.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("failed to persist upload state: %w", err)
+	}
+	return os.Rename(tmp, s.infoPath(info.ID))
+}
+
+// write appends at most info.Length-info.Offset bytes of r to the staged
+// data and advances info.Offset by the number of bytes persisted, even when
+// reading r fails part way.
+func (s *store) write(info *Info, r io.Reader) error {
+	f, err := os.OpenFile(s.dataPath(info.ID), os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Discard anything a previous interrupted write left past the offset.
+	if err := f.Truncate(info.Offset); err != nil {
+		return err
+	}
+	if _, err := f.Seek(info.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	n, copyErr := io.Copy(f, io.LimitReader(r, info.Length-info.Offset))
+	if err := f.Sync(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	info.Offset += n
+	if err := s.save(*info); err != nil {
+		return err
+	}
+	return copyErr
+}
+
+// open returns the staged data for reading.
+func (s *store) open(id string) (*os.File, error) {
+	return os.Open(s.dataPath(id))
+}
+
+// discardData removes the staged bytes but keeps the state file, so a
+// committed upload can still be queried until it expires.
+func (s *store) discardData(id string) {
+	os.Remove(s.dataPath(id))
+}
+
+// remove deletes every trace of an upload.
+func (s *store) remove(id string) {
+	os.Remove(s.dataPath(id))
+	os.Remove(s.infoPath(id))
+}
+
+// sweep removes uploads that expired before now and returns their count.
+func (s *store) sweep(now time.Time) int {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return 0
+	}
+	removed := 0
+	for _, path := range matches {
+		id := filepath.Base(path[:len(path)-len(".json")])
+		unlock := s.lock(id)
+		if _, err := s.get(id, now); errors.Is(err, errNotFound) {
+			s.remove(id)
+			removed++
+		}
+		unlock()
+	}
+	return removed
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validID guards file system paths against anything but generated IDs.
+func validID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
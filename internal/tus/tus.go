@@ -0,0 +1,387 @@
+// Package tus implements the tus 1.0 resumable upload protocol
+// (https://tus.io/protocols/resumable-upload) on top of a local staging
+// directory. Once the final byte of an upload has been received the staged
+// file is committed to Akave in a single pass.
+//
+// Supported extensions are creation, creation-with-upload, termination and
+// expiration. The target bucket and file name are taken from the "bucket"
+// and "filename" keys of the Upload-Metadata header. An upload records the
+// client that created it, and Config.CanAccess decides which clients may
+// query, resume or terminate it.
+package tus
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+const (
+	// Version is the protocol version implemented by Handler.
+	Version = "1.0.0"
+
+	offsetContentType = "application/offset+octet-stream"
+	extensions        = "creation,creation-with-upload,termination,expiration"
+)
+
+// CommitFunc stores a completely received upload in Akave.
+type CommitFunc func(ctx context.Context, bucket, fileName string, r io.Reader) (akavesdk.FileMeta, error)
+
+// Config controls the staging area and limits of a Handler.
+type Config struct {
+	// Dir is the directory staged uploads are written to.
+	Dir string
+	// BasePath is the URL path the handler is mounted at, e.g. "/uploads".
+	BasePath string
+	// MaxSize caps Upload-Length; zero means unlimited.
+	MaxSize int64
+	// Expiration is how long an upload stays resumable after its last
+	// activity. Zero defaults to 24 hours.
+	Expiration time.Duration
+	// Authorize reports whether the request may create an upload into
+	// bucket. Nil allows every bucket.
+	Authorize func(r *http.Request, bucket string) bool
+	// Identify returns the owner and tenant of the client creating an
+	// upload, which are recorded with it. Nil records neither.
+	Identify func(r *http.Request) (owner, tenant string)
+	// CanAccess reports whether the request may query, resume or
+	// terminate an upload; uploads it may not access are reported as not
+	// found. Nil allows every request.
+	CanAccess func(r *http.Request, info Info) bool
+}
+
+// Handler serves the tus protocol endpoints.
+type Handler struct {
+	cfg    Config
+	store  *store
+	commit CommitFunc
+	now    func() time.Time
+}
+
+// NewHandler creates the staging directory and returns a Handler that hands
+// finished uploads to commit.
+func NewHandler(cfg Config, commit CommitFunc) (*Handler, error) {
+	if cfg.Expiration == 0 {
+		cfg.Expiration = 24 * time.Hour
+	}
+	cfg.BasePath = strings.TrimRight(cfg.BasePath, "/")
+
+	st, err := newStore(cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{cfg: cfg, store: st, commit: commit, now: time.Now}, nil
+}
+
+// RunJanitor removes expired uploads every interval until ctx is cancelled.
+func (h *Handler) RunJanitor(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if n := h.store.sweep(h.now()); n > 0 {
+				slog.Info("tus: removed expired uploads", "count", n)
+			}
+		}
+	}
+}
+
+// ServeHTTP dispatches tus requests under BasePath.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", Version)
+
+	method := r.Method
+	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" && method == http.MethodPost {
+		method = override
+	}
+
+	if method == http.MethodOptions {
+		h.options(w)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != Version {
+		w.Header().Set("Tus-Version", Version)
+		http.Error(w, "unsupported or missing Tus-Resumable header", http.StatusPreconditionFailed)
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, h.cfg.BasePath), "/")
+	switch {
+	case id == "" && method == http.MethodPost:
+		h.create(w, r)
+	case id == "":
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	case !validID(id):
+		http.Error(w, errNotFound.Error(), http.StatusNotFound)
+	case method == http.MethodHead:
+		h.head(w, r, id)
+	case method == http.MethodPatch:
+		h.patch(w, r, id)
+	case method == http.MethodDelete:
+		h.terminate(w, r, id)
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) options(w http.ResponseWriter) {
+	w.Header().Set("Tus-Version", Version)
+	w.Header().Set("Tus-Extension", extensions)
+	if h.cfg.MaxSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(h.cfg.MaxSize, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// create handles POST: it registers a new upload and, for
+// creation-with-upload, stores the initial bytes sent with it.
+func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		http.Error(w, "Upload-Defer-Length is not supported", http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		http.Error(w, "invalid or missing Upload-Length", http.StatusBadRequest)
+		return
+	}
+	if h.cfg.MaxSize > 0 && length > h.cfg.MaxSize {
+		http.Error(w, "upload exceeds Tus-Max-Size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	meta, err := parseMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if meta["bucket"] == "" || meta["filename"] == "" {
+		http.Error(w, "Upload-Metadata must contain bucket and filename", http.StatusBadRequest)
+		return
+	}
+	if h.cfg.Authorize != nil && !h.cfg.Authorize(r, meta["bucket"]) {
+		http.Error(w, "not allowed to upload into bucket "+meta["bucket"], http.StatusForbidden)
+		return
+	}
+
+	var owner, tenant string
+	if h.cfg.Identify != nil {
+		owner, tenant = h.cfg.Identify(r)
+	}
+
+	now := h.now()
+	info, err := h.store.create(Info{
+		Bucket:    meta["bucket"],
+		FileName:  meta["filename"],
+		Owner:     owner,
+		Tenant:    tenant,
+		Length:    length,
+		Metadata:  meta,
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.cfg.Expiration),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "tus: create failed", "error", err)
+		http.Error(w, "failed to create upload", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", h.cfg.BasePath+"/"+info.ID)
+	w.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+
+	if r.Header.Get("Content-Type") == offsetContentType || length == 0 {
+		unlock := h.store.lock(info.ID)
+		defer unlock()
+		if !h.receive(w, r, &info) {
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// head reports the current offset of an upload.
+func (h *Handler) head(w http.ResponseWriter, r *http.Request, id string) {
+	unlock := h.store.lock(id)
+	defer unlock()
+
+	info, ok := h.load(w, r, id)
+	if !ok {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(info.Length, 10))
+	w.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	if len(info.Metadata) > 0 {
+		w.Header().Set("Upload-Metadata", formatMetadata(info.Metadata))
+	}
+	if info.Result != nil {
+		w.Header().Set("Akave-Root-CID", info.Result.RootCID)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// patch appends bytes at the client's Upload-Offset.
+func (h *Handler) patch(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Header.Get("Content-Type") != offsetContentType {
+		http.Error(w, "Content-Type must be "+offsetContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "invalid or missing Upload-Offset", http.StatusBadRequest)
+		return
+	}
+
+	unlock := h.store.lock(id)
+	defer unlock()
+
+	info, ok := h.load(w, r, id)
+	if !ok {
+		return
+	}
+	if offset != info.Offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+		http.Error(w, "Upload-Offset does not match the current offset", http.StatusConflict)
+		return
+	}
+	if info.Result != nil {
+		http.Error(w, "upload is already complete", http.StatusForbidden)
+		return
+	}
+
+	if !h.receive(w, r, &info) {
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+	w.Header().Set("Upload-Expires", info.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// receive stores the request body, extends the expiry and commits the upload
+// once complete. It reports false after writing an error response.
+func (h *Handler) receive(w http.ResponseWriter, r *http.Request, info *Info) bool {
+	if r.ContentLength > 0 && info.Offset+r.ContentLength > info.Length {
+		http.Error(w, "request body exceeds Upload-Length", http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	info.ExpiresAt = h.now().Add(h.cfg.Expiration)
+	if err := h.store.write(info, r.Body); err != nil {
+		// The bytes received so far are kept; the client resumes from
+		// the offset reported by a subsequent HEAD request.
+		slog.WarnContext(r.Context(), "tus: upload interrupted", "upload", info.ID, "offset", info.Offset, "error", err)
+		http.Error(w, "failed to store upload data", http.StatusInternalServerError)
+		return false
+	}
+
+	if info.Offset < info.Length {
+		return true
+	}
+	if err := h.finish(r.Context(), info); err != nil {
+		slog.ErrorContext(r.Context(), "tus: commit failed", "upload", info.ID, "bucket", info.Bucket, "file", info.FileName, "error", err)
+		w.Header().Set("Upload-Offset", strconv.FormatInt(info.Offset, 10))
+		http.Error(w, "commit to Akave failed: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	w.Header().Set("Akave-Root-CID", info.Result.RootCID)
+	return true
+}
+
+// finish commits the staged data to Akave. On failure the staged data is
+// kept, and an empty PATCH at the final offset retries the commit.
+func (h *Handler) finish(ctx context.Context, info *Info) error {
+	f, err := h.store.open(info.ID)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	meta, err := h.commit(ctx, info.Bucket, info.FileName, f)
+	if err != nil {
+		return err
+	}
+	info.Result = &meta
+	if err := h.store.save(*info); err != nil {
+		return err
+	}
+	h.store.discardData(info.ID)
+	return nil
+}
+
+// terminate handles DELETE by discarding the upload.
+func (h *Handler) terminate(w http.ResponseWriter, r *http.Request, id string) {
+	unlock := h.store.lock(id)
+	defer unlock()
+
+	if _, ok := h.load(w, r, id); !ok {
+		return
+	}
+	h.store.remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// load fetches upload state, writing a 404 for unknown or expired uploads
+// and for uploads the request may not access.
+func (h *Handler) load(w http.ResponseWriter, r *http.Request, id string) (Info, bool) {
+	info, err := h.store.get(id, h.now())
+	if err == nil && h.cfg.CanAccess != nil && !h.cfg.CanAccess(r, info) {
+		err = errNotFound
+	}
+	if errors.Is(err, errNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return Info{}, false
+	}
+	if err != nil {
+		slog.Error("tus: failed to load upload", "upload", id, "error", err)
+		http.Error(w, "failed to load upload", http.StatusInternalServerError)
+		return Info{}, false
+	}
+	return info, true
+}
+
+// parseMetadata decodes "key base64value,key2 base64value2".
+func parseMetadata(s string) (map[string]string, error) {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, enc, _ := strings.Cut(pair, " ")
+		v, err := base64.StdEncoding.DecodeString(strings.TrimSpace(enc))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %q", key)
+		}
+		meta[key] = string(v)
+	}
+	return meta, nil
+}
+
+func formatMetadata(meta map[string]string) string {
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + " " + base64.StdEncoding.EncodeToString([]byte(meta[k]))
+	}
+	return strings.Join(pairs, ",")
+}
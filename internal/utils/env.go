@@ -3,7 +3,7 @@ package utils
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -50,11 +50,11 @@ func LoadEnvConfig() {
 		// 2. If DOTENV_PATH is not set, try to find the module root and load .env from there
 		moduleRoot, err := FindModuleRoot()
 		if err != nil {
-			log.Printf("Warning: Failed to find Go module root: %v. .env won't be loaded automatically.", err)
+			slog.Warn("failed to find Go module root; .env won't be loaded automatically", "error", err)
 		} else if moduleRoot != "" {
 			dotenvPath = filepath.Join(moduleRoot, ".env")
 		} else {
-			log.Printf("Warning: Could not determine Go module root. .env won't be loaded automatically.")
+			slog.Warn("could not determine Go module root; .env won't be loaded automatically")
 		}
 	}
 
@@ -62,11 +62,11 @@ func LoadEnvConfig() {
 	if dotenvPath != "" {
 		err := godotenv.Load(dotenvPath)
 		if err != nil {
-			log.Printf("Warning: Could not load .env file from %s: %v. Relying on system environment variables.", dotenvPath, err)
+			slog.Warn("could not load .env file; relying on system environment variables", "path", dotenvPath, "error", err)
 		} else {
-			log.Printf("Successfully loaded .env file from %s.", dotenvPath)
+			slog.Info("loaded .env file", "path", dotenvPath)
 		}
 	} else {
-		log.Println("Warning: No .env path determined. Relying solely on system environment variables.")
+		slog.Warn("no .env path determined; relying solely on system environment variables")
 	}
 }
\ No newline at end of file
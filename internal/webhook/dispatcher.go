@@ -0,0 +1,304 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/akave-ai/go-akavelink/internal/metrics"
+)
+
+// Delivery results counted in akavelink_webhook_deliveries_total.
+const (
+	resultDelivered    = "delivered"
+	resultRetried      = "retried"
+	resultDeadLettered = "dead_lettered"
+)
+
+var errShuttingDown = errors.New("server shutting down")
+
+// Config controls how a Dispatcher delivers events.
+type Config struct {
+	// Subscriptions are the endpoints events are delivered to.
+	Subscriptions *Store
+	// DeadLetterPath is a file that deliveries failing for good are
+	// appended to as JSON lines. Empty only logs them.
+	DeadLetterPath string
+	// MaxAttempts counts the first attempt. Zero defaults to 5.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry; it doubles with
+	// every retry up to MaxBackoff. Zero defaults to 1s and 1m.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout bounds each attempt. Zero defaults to 10s.
+	Timeout time.Duration
+	// Workers is the number of deliveries made at once. Zero defaults to 4.
+	Workers int
+	// QueueSize caps the deliveries waiting for a worker; more are dead
+	// lettered. Zero defaults to 1000.
+	QueueSize int
+	// Client sends the requests. Nil uses a client without a timeout of
+	// its own.
+	Client *http.Client
+}
+
+// Dispatcher delivers events to the subscribed endpoints in the background.
+type Dispatcher struct {
+	cfg   Config
+	now   func() time.Time
+	queue chan *delivery
+
+	mu       sync.Mutex
+	closed   bool
+	retrying map[*delivery]*time.Timer
+
+	deadMu sync.Mutex
+}
+
+// delivery is one event on its way to one subscription.
+type delivery struct {
+	sub      string
+	url      string
+	event    Event
+	body     []byte
+	attempts int
+}
+
+// DeadLetter is an entry of the dead-letter log.
+type DeadLetter struct {
+	Time         time.Time       `json:"time"`
+	Subscription string          `json:"subscription"`
+	URL          string          `json:"url"`
+	Attempts     int             `json:"attempts"`
+	Error        string          `json:"error"`
+	Event        json.RawMessage `json:"event"`
+}
+
+// NewDispatcher returns a Dispatcher; deliveries start once Run is called.
+func NewDispatcher(cfg Config) *Dispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{}
+	}
+	return &Dispatcher{
+		cfg:      cfg,
+		now:      time.Now,
+		queue:    make(chan *delivery, cfg.QueueSize),
+		retrying: make(map[*delivery]*time.Timer),
+	}
+}
+
+// Subscriptions returns the store of subscriptions.
+func (d *Dispatcher) Subscriptions() *Store {
+	return d.cfg.Subscriptions
+}
+
+// Emit queues ev for every subscription of ev.Tenant interested in it. ID
+// and Time are filled in when empty. Emit never blocks.
+func (d *Dispatcher) Emit(ev Event) {
+	subs := d.cfg.Subscriptions.matching(ev.Type, ev.Tenant)
+	if len(subs) == 0 {
+		return
+	}
+	if ev.ID == "" {
+		ev.ID, _ = randomHex(16)
+	}
+	if ev.Time.IsZero() {
+		ev.Time = d.now().UTC()
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("webhook: failed to encode event", "type", ev.Type, "error", err)
+		return
+	}
+	for _, sub := range subs {
+		d.enqueue(&delivery{sub: sub.ID, url: sub.URL, event: ev, body: body})
+	}
+}
+
+// Run delivers events until ctx is cancelled. Deliveries still queued or
+// waiting for a retry then are dead lettered.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range d.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case dl := <-d.queue:
+					d.deliver(ctx, dl)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	d.mu.Lock()
+	d.closed = true
+	for dl, t := range d.retrying {
+		if t.Stop() {
+			d.deadLetter(dl, errShuttingDown)
+		}
+	}
+	clear(d.retrying)
+	d.mu.Unlock()
+	for {
+		select {
+		case dl := <-d.queue:
+			d.deadLetter(dl, errShuttingDown)
+		default:
+			return
+		}
+	}
+}
+
+// enqueue hands dl to the workers, or dead letters it when the queue is
+// full or the dispatcher has stopped.
+func (d *Dispatcher) enqueue(dl *delivery) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		d.deadLetter(dl, errShuttingDown)
+		return
+	}
+	select {
+	case d.queue <- dl:
+	default:
+		d.deadLetter(dl, errors.New("delivery queue full"))
+	}
+}
+
+// deliver makes one attempt and schedules a retry if it fails.
+func (d *Dispatcher) deliver(ctx context.Context, dl *delivery) {
+	sub, ok := d.cfg.Subscriptions.get(dl.sub)
+	if !ok {
+		return
+	}
+	dl.attempts++
+	err := d.post(ctx, sub, dl)
+	if err == nil {
+		metrics.WebhookDelivery(resultDelivered)
+		return
+	}
+	if ctx.Err() != nil || dl.attempts >= d.cfg.MaxAttempts {
+		d.deadLetter(dl, err)
+		return
+	}
+
+	delay := d.backoff(dl.attempts)
+	metrics.WebhookDelivery(resultRetried)
+	slog.Warn("webhook: delivery failed; retrying", "subscription", dl.sub, "event", dl.event.ID,
+		"type", dl.event.Type, "attempt", dl.attempts, "delay", delay, "error", err)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		d.deadLetter(dl, err)
+		return
+	}
+	d.retrying[dl] = time.AfterFunc(delay, func() {
+		d.mu.Lock()
+		delete(d.retrying, dl)
+		d.mu.Unlock()
+		d.enqueue(dl)
+	})
+}
+
+// post sends dl to sub once.
+func (d *Dispatcher) post(ctx context.Context, sub Subscription, dl *delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-akavelink-webhook")
+	req.Header.Set(EventHeader, string(dl.event.Type))
+	req.Header.Set(DeliveryHeader, dl.event.ID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, d.now(), dl.body))
+
+	resp, err := d.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+// backoff returns the delay before retry number attempt, with jitter.
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.cfg.InitialBackoff
+	for i := 1; i < attempt && delay < d.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.cfg.MaxBackoff)
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// deadLetter logs a delivery that failed for good and appends it to the
+// dead-letter log.
+func (d *Dispatcher) deadLetter(dl *delivery, err error) {
+	metrics.WebhookDelivery(resultDeadLettered)
+	slog.Error("webhook: delivery failed for good", "subscription", dl.sub, "url", dl.url,
+		"event", dl.event.ID, "type", dl.event.Type, "attempts", dl.attempts, "error", err)
+	if d.cfg.DeadLetterPath == "" {
+		return
+	}
+
+	line, _ := json.Marshal(DeadLetter{
+		Time:         d.now().UTC(),
+		Subscription: dl.sub,
+		URL:          dl.url,
+		Attempts:     dl.attempts,
+		Error:        err.Error(),
+		Event:        dl.body,
+	})
+	d.deadMu.Lock()
+	defer d.deadMu.Unlock()
+	f, ferr := os.OpenFile(d.cfg.DeadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if ferr == nil {
+		_, ferr = f.Write(append(line, '\n'))
+		if cerr := f.Close(); ferr == nil {
+			ferr = cerr
+		}
+	}
+	if ferr != nil {
+		slog.Error("webhook: failed to write dead-letter log", "path", d.cfg.DeadLetterPath, "error", ferr)
+	}
+}
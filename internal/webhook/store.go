@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Errors returned when managing subscriptions.
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrInvalidSubscription  = errors.New("invalid webhook subscription")
+)
+
+// Subscription registers an endpoint for events.
+type Subscription struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Events restricts the subscription to the listed types; empty
+	// subscribes to every event.
+	Events []EventType `json:"events,omitempty"`
+	// Tenant owns the subscription, which only receives the events of that
+	// tenant; empty for the server's default wallet.
+	Tenant string `json:"tenant,omitempty"`
+	// Secret keys the signatures of deliveries. It is only returned when
+	// the subscription is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Wants reports whether the subscription receives events of type t.
+func (s Subscription) Wants(t EventType) bool {
+	return len(s.Events) == 0 || slices.Contains(s.Events, t)
+}
+
+// Store keeps subscriptions in a JSON file. All subscriptions are held in
+// memory; every change rewrites the file atomically.
+type Store struct {
+	path string
+	now  func() time.Time
+
+	mu   sync.RWMutex
+	subs map[string]Subscription
+}
+
+// OpenStore loads the subscriptions in path, creating an empty store when
+// the file does not exist yet.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, now: time.Now, subs: make(map[string]Subscription)}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook file: %w", err)
+	}
+	var subs []Subscription
+	if err := json.Unmarshal(b, &subs); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook file: %w", err)
+	}
+	for _, sub := range subs {
+		s.subs[sub.ID] = sub
+	}
+	return s, nil
+}
+
+// Create registers a subscription with the URL, events, tenant and secret
+// of spec.
+// A secret is generated when spec has none. The returned subscription
+// includes the secret.
+func (s *Store) Create(spec Subscription) (Subscription, error) {
+	u, err := url.Parse(spec.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Subscription{}, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+	for _, t := range spec.Events {
+		if _, err := ParseEventType(string(t)); err != nil {
+			return Subscription{}, err
+		}
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return Subscription{}, err
+	}
+	if spec.Secret == "" {
+		if spec.Secret, err = randomHex(32); err != nil {
+			return Subscription{}, err
+		}
+	}
+	sub := Subscription{
+		ID:        id,
+		URL:       spec.URL,
+		Events:    spec.Events,
+		Tenant:    spec.Tenant,
+		Secret:    spec.Secret,
+		CreatedAt: s.now().UTC().Truncate(time.Second),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[id] = sub
+	if err := s.persist(); err != nil {
+		delete(s.subs, id)
+		return Subscription{}, err
+	}
+	return sub, nil
+}
+
+// List returns every subscription without its secret, ordered by creation
+// time.
+func (s *Store) List() []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		sub.Secret = ""
+		out = append(out, sub)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// Get returns the subscription with the given ID without its secret.
+func (s *Store) Get(id string) (Subscription, error) {
+	sub, ok := s.get(id)
+	if !ok {
+		return Subscription{}, fmt.Errorf("webhook %q: %w", id, ErrSubscriptionNotFound)
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+// Delete removes the subscription with the given ID. Deliveries to it that
+// are waiting for a retry are dropped.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[id]
+	if !ok {
+		return fmt.Errorf("failed to delete webhook %q: %w", id, ErrSubscriptionNotFound)
+	}
+	delete(s.subs, id)
+	if err := s.persist(); err != nil {
+		s.subs[id] = sub
+		return err
+	}
+	return nil
+}
+
+// get returns the subscription with the given ID, including its secret.
+func (s *Store) get(id string) (Subscription, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub, ok := s.subs[id]
+	return sub, ok
+}
+
+// matching returns the subscriptions of tenant receiving events of type t.
+func (s *Store) matching(t EventType, tenant string) []Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []Subscription
+	for _, sub := range s.subs {
+		if sub.Tenant == tenant && sub.Wants(t) {
+			out = append(out, sub)
+		}
+	}
+	return out
+}
+
+// persist writes all subscriptions to disk. The caller must hold s.mu for
+// writing.
+func (s *Store) persist() error {
+	subs := make([]Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+
+	b, err := json.MarshalIndent(subs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to persist webhooks: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("failed to persist webhooks: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
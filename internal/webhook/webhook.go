@@ -0,0 +1,119 @@
+// Package webhook notifies subscribed endpoints of changes to buckets and
+// files. Every event is POSTed as JSON to the subscriptions interested in
+// it, signed with the subscription's secret; failed deliveries are retried
+// with exponential backoff and end up in a dead-letter log when they keep
+// failing.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+// Headers sent with every delivery.
+const (
+	// EventHeader carries the event type.
+	EventHeader = "X-Akavelink-Event"
+	// DeliveryHeader carries the event ID, which stays the same across
+	// retries so receivers can discard duplicates.
+	DeliveryHeader = "X-Akavelink-Delivery"
+	// SignatureHeader carries the signature of the body, see Sign.
+	SignatureHeader = "X-Akavelink-Signature"
+)
+
+// EventType names a kind of event.
+type EventType string
+
+// Event types.
+const (
+	BucketCreated EventType = "bucket.created"
+	BucketDeleted EventType = "bucket.deleted"
+	FileUploaded  EventType = "file.uploaded"
+	FileDeleted   EventType = "file.deleted"
+	// FileFailed reports an upload that was not stored.
+	FileFailed EventType = "file.failed"
+)
+
+// EventTypes lists every event type.
+var EventTypes = []EventType{BucketCreated, BucketDeleted, FileUploaded, FileDeleted, FileFailed}
+
+// ParseEventType validates s as a known event type.
+func ParseEventType(s string) (EventType, error) {
+	for _, t := range EventTypes {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unknown event type %q", ErrInvalidSubscription, s)
+}
+
+// Event is the payload of a delivery.
+type Event struct {
+	ID     string    `json:"id"`
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	Bucket string    `json:"bucket"`
+	File   string    `json:"file,omitempty"`
+	// Tenant is the tenant of the API key that caused the event.
+	Tenant string `json:"tenant,omitempty"`
+	// FileMeta describes the stored file of a file.uploaded event.
+	FileMeta *akavesdk.FileMeta `json:"fileMeta,omitempty"`
+	// Error and Code describe why the upload of a file.failed event failed.
+	Error string `json:"error,omitempty"`
+	Code  string `json:"code,omitempty"`
+}
+
+// Sign returns the SignatureHeader value of body sent at t:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256>", where the HMAC keyed with secret
+// covers the timestamp, a dot and the body. Receivers recompute it and
+// reject old timestamps to guard against replays.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + signature(secret, ts, body)
+}
+
+func signature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ErrInvalidSignature is returned by Verify for signatures that do not
+// match or are too old.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Verify checks a SignatureHeader value against body and rejects
+// signatures made more than tolerance before now.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, field := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return fmt.Errorf("%w: malformed header", ErrInvalidSignature)
+	}
+	if now.Sub(time.Unix(unix, 0)) > tolerance {
+		return fmt.Errorf("%w: timestamp too old", ErrInvalidSignature)
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, ts, body))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthStore_Lifecycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := auth.OpenStore(path)
+	require.NoError(t, err)
+
+	key, token, err := store.Create(auth.Key{Name: "ci", Scopes: []auth.Scope{auth.ScopeFilesRead}, Buckets: []string{"builds"}})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "akl_"+key.ID+"_"))
+
+	_, _, err = store.Create(auth.Key{Name: "bad", Scopes: []auth.Scope{"files:everything"}})
+	assert.ErrorIs(t, err, auth.ErrInvalidScope)
+
+	reopened, err := auth.OpenStore(path)
+	require.NoError(t, err)
+	got, err := reopened.Authenticate(token)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, got.ID)
+	assert.True(t, got.HasScope(auth.ScopeFilesRead))
+	assert.False(t, got.HasScope(auth.ScopeFilesWrite))
+	assert.True(t, got.CanAccessBucket("builds"))
+	assert.False(t, got.CanAccessBucket("other"))
+
+	_, err = reopened.Authenticate(token + "x")
+	assert.ErrorIs(t, err, auth.ErrInvalidKey)
+
+	require.NoError(t, reopened.Revoke(key.ID))
+	_, err = reopened.Authenticate(token)
+	assert.ErrorIs(t, err, auth.ErrInvalidKey)
+	assert.ErrorIs(t, reopened.Revoke(key.ID), auth.ErrKeyNotFound)
+}
+
+func TestServer_APIKeys(t *testing.T) {
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	_, admin, err := store.Create(auth.Key{Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	require.NoError(t, err)
+
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{Keys: store})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+
+	resp, _ := apiRequest(t, ts, http.MethodGet, "/health", nil, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "health stays public")
+
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets", nil, nil)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("WWW-Authenticate"))
+
+	for _, b := range []string{"alpha", "bravo"} {
+		resp, _ = apiRequest(t, ts, http.MethodPost, "/buckets/"+b, nil, bearer(admin))
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	resp, body := apiRequest(t, ts, http.MethodPost, "/admin/keys",
+		strings.NewReader(`{"name":"reader","scopes":["buckets:read","files:read"],"buckets":["alpha"]}`), bearer(admin))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var minted struct {
+		ID    string `json:"id"`
+		Token string `json:"token"`
+	}
+	decodeAPI(t, body, &minted)
+	require.NotEmpty(t, minted.Token)
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets", nil, map[string]string{"X-API-Key": minted.Token})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var buckets []akavesdk.Bucket
+	decodeAPI(t, body, &buckets)
+	require.Len(t, buckets, 1, "restricted keys only see their buckets")
+	assert.Equal(t, "alpha", buckets[0].Name)
+
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets/bravo/files", nil, bearer(minted.Token))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodPut, "/buckets/alpha/files/a.txt", strings.NewReader("a"), bearer(minted.Token))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/admin/keys", nil, bearer(minted.Token))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/admin/keys", nil, bearer(admin))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotContains(t, string(body), "hash")
+	var keys []auth.Key
+	decodeAPI(t, body, &keys)
+	assert.Len(t, keys, 2)
+
+	resp, _ = apiRequest(t, ts, http.MethodDelete, "/admin/keys/"+minted.ID, nil, bearer(admin))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets", nil, bearer(minted.Token))
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,167 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBucketStorage keeps buckets in a map and reports errors with the same
+// messages as the Akave SDK and storage contract. Operations it does not
+// implement panic through the nil embedded Storage.
+type fakeBucketStorage struct {
+	akavesdk.Storage
+
+	mu      sync.Mutex
+	buckets map[string]akavesdk.Bucket
+}
+
+func newFakeBucketStorage() *fakeBucketStorage {
+	return &fakeBucketStorage{buckets: make(map[string]akavesdk.Bucket)}
+}
+
+func (f *fakeBucketStorage) CreateBucket(_ context.Context, name string) (akavesdk.Bucket, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(name) < 3 {
+		return akavesdk.Bucket{}, errors.New("sdk: invalid bucket name")
+	}
+	if _, ok := f.buckets[name]; ok {
+		return akavesdk.Bucket{}, errors.New("execution reverted: BucketAlreadyExists")
+	}
+	b := akavesdk.Bucket{ID: "id-" + name, Name: name, CreatedAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f.buckets[name] = b
+	return b, nil
+}
+
+func (f *fakeBucketStorage) ViewBucket(_ context.Context, name string) (akavesdk.Bucket, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(name) < 3 {
+		return akavesdk.Bucket{}, errors.New("sdk: invalid bucket name")
+	}
+	b, ok := f.buckets[name]
+	if !ok {
+		return akavesdk.Bucket{}, errors.New("execution reverted: BucketNonexists")
+	}
+	return b, nil
+}
+
+func (f *fakeBucketStorage) DeleteBucket(_ context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(name) < 3 {
+		return errors.New("sdk: invalid bucket name")
+	}
+	if _, ok := f.buckets[name]; !ok {
+		return errors.New("execution reverted: BucketNonexists")
+	}
+	delete(f.buckets, name)
+	return nil
+}
+
+func (f *fakeBucketStorage) ListBuckets(context.Context) ([]akavesdk.Bucket, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]akavesdk.Bucket, 0, len(f.buckets))
+	for _, b := range f.buckets {
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+// bucketResponse mirrors the JSON envelope of a bucket endpoint.
+type bucketResponse struct {
+	Success bool            `json:"success"`
+	Data    akavesdk.Bucket `json:"data"`
+	Error   string          `json:"error"`
+}
+
+func newBucketTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv, err := server.New(newFakeBucketStorage(), server.Options{})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func bucketRequest(t *testing.T, ts *httptest.Server, method, name string) (int, bucketResponse) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+"/buckets/"+name, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var body bucketResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	return resp.StatusCode, body
+}
+
+// TestBuckets_CreateViewDelete walks a bucket through its lifecycle.
+func TestBuckets_CreateViewDelete(t *testing.T) {
+	ts := newBucketTestServer(t)
+
+	status, body := bucketRequest(t, ts, http.MethodPost, "photos")
+	require.Equal(t, http.StatusCreated, status)
+	assert.True(t, body.Success)
+	assert.Equal(t, "photos", body.Data.Name)
+	assert.Equal(t, "id-photos", body.Data.ID)
+
+	status, body = bucketRequest(t, ts, http.MethodGet, "photos")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, body.Success)
+	assert.Equal(t, "photos", body.Data.Name)
+	assert.False(t, body.Data.CreatedAt.IsZero())
+
+	status, body = bucketRequest(t, ts, http.MethodDelete, "photos")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, body.Success)
+	assert.Equal(t, "photos", body.Data.Name)
+
+	status, _ = bucketRequest(t, ts, http.MethodGet, "photos")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+// TestBuckets_NotFound checks that viewing or deleting a missing bucket is a 404.
+func TestBuckets_NotFound(t *testing.T) {
+	ts := newBucketTestServer(t)
+
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		status, body := bucketRequest(t, ts, method, "missing")
+		assert.Equal(t, http.StatusNotFound, status, method)
+		assert.False(t, body.Success, method)
+		assert.Contains(t, body.Error, "BucketNonexists", method)
+	}
+}
+
+// TestBuckets_InvalidInput checks that rejected bucket names are a 400 and
+// that creating an existing bucket is a 409.
+func TestBuckets_InvalidInput(t *testing.T) {
+	ts := newBucketTestServer(t)
+
+	for _, method := range []string{http.MethodPost, http.MethodGet, http.MethodDelete} {
+		status, body := bucketRequest(t, ts, method, "ab")
+		assert.Equal(t, http.StatusBadRequest, status, method)
+		assert.False(t, body.Success, method)
+		assert.Contains(t, body.Error, "invalid bucket name", method)
+	}
+
+	status, _ := bucketRequest(t, ts, http.MethodPost, "photos")
+	require.Equal(t, http.StatusCreated, status)
+	status, body := bucketRequest(t, ts, http.MethodPost, "photos")
+	assert.Equal(t, http.StatusConflict, status)
+	assert.False(t, body.Success)
+}
@@ -0,0 +1,385 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/cache"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/akave-ai/go-akavelink/internal/tenant"
+)
+
+func newTestCache(t *testing.T, cfg cache.Config) *cache.Cache {
+	t.Helper()
+	cfg.Dir = t.TempDir()
+	c, err := cache.New(cfg)
+	require.NoError(t, err)
+	return c
+}
+
+// fillWith returns a fill writing content and counting its calls.
+func fillWith(content string, calls *atomic.Int32) func(context.Context, io.Writer) error {
+	return func(_ context.Context, w io.Writer) error {
+		calls.Add(1)
+		_, err := io.WriteString(w, content)
+		return err
+	}
+}
+
+func readCached(t *testing.T, f *os.File) string {
+	t.Helper()
+	require.NotNil(t, f)
+	defer f.Close()
+	b, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestCache_Get(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, cache.Config{MaxSize: 10})
+	key := cache.Key{Bucket: "b", File: "a.txt", RootCID: "cid-1"}
+	var calls atomic.Int32
+
+	f, result, err := c.Get(ctx, key, 5, fillWith("hello", &calls))
+	require.NoError(t, err)
+	assert.Equal(t, cache.Miss, result)
+	assert.Equal(t, "hello", readCached(t, f))
+
+	f, result, err = c.Get(ctx, key, 5, fillWith("hello", &calls))
+	require.NoError(t, err)
+	assert.Equal(t, cache.Hit, result)
+	assert.Equal(t, "hello", readCached(t, f))
+	assert.EqualValues(t, 1, calls.Load())
+
+	// A new root CID is a different file.
+	f, result, err = c.Get(ctx, cache.Key{Bucket: "b", File: "a.txt", RootCID: "cid-2"}, 5, fillWith("world", &calls))
+	require.NoError(t, err)
+	assert.Equal(t, cache.Miss, result)
+	assert.Equal(t, "world", readCached(t, f))
+	assert.EqualValues(t, 10, c.Size())
+
+	// Files larger than the cache are not cached.
+	f, result, err = c.Get(ctx, cache.Key{Bucket: "b", File: "big"}, 11, fillWith("", &calls))
+	require.NoError(t, err)
+	assert.Equal(t, cache.Bypass, result)
+	assert.Nil(t, f)
+
+	// Failed and short fills are not cached.
+	_, _, err = c.Get(ctx, cache.Key{Bucket: "b", File: "bad"}, 3, func(context.Context, io.Writer) error {
+		return errors.New("storage down")
+	})
+	assert.EqualError(t, err, "storage down")
+	_, _, err = c.Get(ctx, cache.Key{Bucket: "b", File: "short"}, 3, fillWith("ab", &calls))
+	assert.Error(t, err)
+	assert.EqualValues(t, 10, c.Size())
+
+	c.Invalidate("", "b", "a.txt")
+	assert.Zero(t, c.Size())
+}
+
+// TestCache_InvalidateTenant only drops the copies of the given tenant.
+func TestCache_InvalidateTenant(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, cache.Config{MaxSize: 100})
+	var calls atomic.Int32
+	for _, tenantID := range []string{"", "team-a"} {
+		for _, file := range []string{"a.txt", "b.txt"} {
+			f, _, err := c.Get(ctx, cache.Key{Tenant: tenantID, Bucket: "b", File: file, RootCID: "cid"}, 5, fillWith("hello", &calls))
+			require.NoError(t, err)
+			f.Close()
+		}
+	}
+	assert.EqualValues(t, 4, calls.Load(), "tenants do not share cached copies")
+
+	c.Invalidate("team-a", "b", "a.txt")
+	assert.EqualValues(t, 15, c.Size())
+	c.InvalidateBucket("", "b")
+	assert.EqualValues(t, 5, c.Size())
+
+	_, result, err := c.Get(ctx, cache.Key{Tenant: "team-a", Bucket: "b", File: "b.txt", RootCID: "cid"}, 5, fillWith("hello", &calls))
+	require.NoError(t, err)
+	assert.Equal(t, cache.Hit, result)
+}
+
+func TestCache_Eviction(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, cache.Config{MaxSize: 10})
+	var calls atomic.Int32
+	key := func(name string) cache.Key { return cache.Key{Bucket: "b", File: name, RootCID: name} }
+
+	for _, name := range []string{"one", "two"} {
+		f, _, err := c.Get(ctx, key(name), 4, fillWith(name+"!", &calls))
+		require.NoError(t, err)
+		f.Close()
+	}
+	// Using "one" makes "two" the least recently used.
+	f, result, err := c.Get(ctx, key("one"), 4, fillWith("one!", &calls))
+	require.NoError(t, err)
+	assert.Equal(t, cache.Hit, result)
+	f.Close()
+
+	f, _, err = c.Get(ctx, key("six"), 4, fillWith("six!", &calls))
+	require.NoError(t, err)
+	f.Close()
+	assert.EqualValues(t, 8, c.Size())
+
+	// "one" is checked first: refetching "two" evicts it again.
+	for _, tc := range []struct {
+		name string
+		want cache.Result
+	}{{"one", cache.Hit}, {"two", cache.Miss}} {
+		f, result, err := c.Get(ctx, key(tc.name), 4, fillWith(tc.name+"!", &calls))
+		require.NoError(t, err)
+		f.Close()
+		assert.Equal(t, tc.want, result, tc.name)
+	}
+
+	c.InvalidateBucket("", "b")
+	assert.Zero(t, c.Size())
+}
+
+func TestCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCache(t, cache.Config{MaxSize: 10, TTL: 50 * time.Millisecond})
+	key := cache.Key{Bucket: "b", File: "a.txt", RootCID: "cid"}
+	var calls atomic.Int32
+
+	f, _, err := c.Get(ctx, key, 5, fillWith("hello", &calls))
+	require.NoError(t, err)
+	f.Close()
+	time.Sleep(100 * time.Millisecond)
+	f, result, err := c.Get(ctx, key, 5, fillWith("hello", &calls))
+	require.NoError(t, err)
+	f.Close()
+	assert.Equal(t, cache.Miss, result)
+	assert.EqualValues(t, 2, calls.Load())
+}
+
+// TestCache_SingleFlight fetches a file once for concurrent misses.
+func TestCache_SingleFlight(t *testing.T) {
+	c := newTestCache(t, cache.Config{MaxSize: 1 << 20})
+	key := cache.Key{Bucket: "b", File: "a.txt", RootCID: "cid"}
+	release := make(chan struct{})
+	var calls atomic.Int32
+	fill := func(_ context.Context, w io.Writer) error {
+		calls.Add(1)
+		<-release
+		_, err := io.WriteString(w, "shared")
+		return err
+	}
+
+	// A cancelled waiter does not cancel the fetch for the others.
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := c.Get(cancelled, key, 6, fill)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	var wg sync.WaitGroup
+	results := make([]string, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, _, err := c.Get(context.Background(), key, 6, fill)
+			if assert.NoError(t, err) {
+				results[i] = readCached(t, f)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.EqualValues(t, 1, calls.Load())
+	for _, r := range results {
+		assert.Equal(t, "shared", r)
+	}
+}
+
+// TestCache_RemovesStaleFiles starts empty after a restart.
+func TestCache_RemovesStaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.blob"), []byte("x"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "keep.txt"), []byte("x"), 0o600))
+
+	_, err := cache.New(cache.Config{Dir: dir, MaxSize: 10})
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "old.blob"))
+	assert.FileExists(t, filepath.Join(dir, "keep.txt"))
+}
+
+// countingStorage counts the full and range downloads fetched from storage.
+type countingStorage struct {
+	akavesdk.Storage
+	downloads atomic.Int32
+	ranges    atomic.Int32
+}
+
+func (c *countingStorage) DownloadFile(ctx context.Context, bucketName, fileName string, w io.Writer) error {
+	c.downloads.Add(1)
+	return c.Storage.DownloadFile(ctx, bucketName, fileName, w)
+}
+
+func (c *countingStorage) DownloadRange(ctx context.Context, bucketName, fileName string, offset, length int64, w io.Writer) error {
+	c.ranges.Add(1)
+	return c.Storage.DownloadRange(ctx, bucketName, fileName, offset, length, w)
+}
+
+// TestServer_DownloadCache serves repeated downloads from the disk cache
+// and drops cached copies of deleted and replaced files.
+func TestServer_DownloadCache(t *testing.T) {
+	storage := &countingStorage{Storage: akavesdk.NewMemoryStorage()}
+	srv, err := server.New(storage, server.Options{Cache: newTestCache(t, cache.Config{MaxSize: 1 << 20})})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	upload := func(content string) {
+		t.Helper()
+		resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/cached/files/a.txt", strings.NewReader(content), nil)
+		require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	}
+	download := func(want string, result cache.Result, headers map[string]string) {
+		t.Helper()
+		resp, body := apiRequest(t, ts, http.MethodGet, "/buckets/cached/files/a.txt/download", nil, headers)
+		require.Less(t, resp.StatusCode, 300, string(body))
+		assert.Equal(t, want, string(body))
+		assert.Equal(t, string(result), resp.Header.Get(cache.Header))
+	}
+
+	upload("hello cache")
+	download("hello cache", cache.Miss, nil)
+	download("hello cache", cache.Hit, nil)
+	download("cache", cache.Hit, map[string]string{"Range": "bytes=6-"})
+	assert.EqualValues(t, 1, storage.downloads.Load())
+
+	resp, body := apiRequest(t, ts, http.MethodDelete, "/buckets/cached/files/a.txt", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	upload("replaced")
+	download("replaced", cache.Miss, nil)
+	assert.EqualValues(t, 2, storage.downloads.Load())
+
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets/cached/files/missing/download", nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(cache.Header))
+}
+
+// TestServer_DownloadCacheRangeMiss serves ranges of uncached files from
+// storage without fetching the whole file into the cache first.
+func TestServer_DownloadCacheRangeMiss(t *testing.T) {
+	storage := &countingStorage{Storage: akavesdk.NewMemoryStorage()}
+	srv, err := server.New(storage, server.Options{Cache: newTestCache(t, cache.Config{MaxSize: 1 << 20})})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/cached/files/a.txt", strings.NewReader("hello cache"), nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/cached/files/a.txt/download", nil, map[string]string{"Range": "bytes=6-"})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode, string(body))
+	assert.Equal(t, "cache", string(body))
+	assert.Equal(t, string(cache.Bypass), resp.Header.Get(cache.Header))
+	assert.EqualValues(t, 0, storage.downloads.Load(), "a range miss does not fetch the whole file")
+	assert.EqualValues(t, 1, storage.ranges.Load())
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/cached/files/a.txt/download", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	assert.Equal(t, string(cache.Miss), resp.Header.Get(cache.Header))
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/cached/files/a.txt/download", nil, map[string]string{"Range": "bytes=0-4"})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode, string(body))
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, string(cache.Hit), resp.Header.Get(cache.Header))
+	assert.EqualValues(t, 1, storage.downloads.Load())
+	assert.EqualValues(t, 1, storage.ranges.Load(), "cached files serve ranges from disk")
+}
+
+// blockingStorage holds full downloads until release is closed and fails
+// them once the storage has been closed.
+type blockingStorage struct {
+	akavesdk.Storage
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+	closed  atomic.Bool
+}
+
+func (b *blockingStorage) DownloadFile(ctx context.Context, bucketName, fileName string, w io.Writer) error {
+	b.once.Do(func() { close(b.started) })
+	<-b.release
+	if b.closed.Load() {
+		return errors.New("storage closed")
+	}
+	return b.Storage.DownloadFile(ctx, bucketName, fileName, w)
+}
+
+func (b *blockingStorage) Close() error {
+	b.closed.Store(true)
+	return nil
+}
+
+// TestServer_DownloadCacheHoldsTenantClient keeps a tenant's client open
+// while a cache fill outlives the request that started it.
+func TestServer_DownloadCacheHoldsTenantClient(t *testing.T) {
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	_, keyA, err := store.Create(auth.Key{Name: "a", Scopes: []auth.Scope{auth.ScopeFilesRead, auth.ScopeFilesWrite}, Tenant: "team-a"})
+	require.NoError(t, err)
+
+	storage := &blockingStorage{Storage: akavesdk.NewMemoryStorage(), started: make(chan struct{}), release: make(chan struct{})}
+	pool := tenant.NewPool(newTestRegistry(t), func(tenant.Tenant) (akavesdk.Storage, error) { return storage, nil }, time.Nanosecond)
+	defer pool.Close()
+	srv, err := server.New(nil, server.Options{Keys: store, Tenants: pool, Cache: newTestCache(t, cache.Config{MaxSize: 1 << 20})})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	bearer := map[string]string{"Authorization": "Bearer " + keyA}
+
+	resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/cached/files/a.txt", strings.NewReader("hello cache"), bearer)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/buckets/cached/files/a.txt/download", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+keyA)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-storage.started
+	cancel()
+	<-done
+
+	// The request has gone, but the fill it started still uses the client.
+	for deadline := time.Now().Add(50 * time.Millisecond); time.Now().Before(deadline); {
+		assert.Zero(t, pool.Evict())
+		time.Sleep(time.Millisecond)
+	}
+	close(storage.release)
+
+	require.Eventually(t, func() bool {
+		resp, _ := apiRequest(t, ts, http.MethodGet, "/buckets/cached/files/a.txt/download", nil, bearer)
+		return resp.Header.Get(cache.Header) == string(cache.Hit)
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.False(t, storage.closed.Load())
+}
@@ -0,0 +1,222 @@
+package test
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/s3"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+)
+
+// TestServer_UploadChecksums rejects bodies not matching the declared
+// digests and reports the digests of stored files.
+func TestServer_UploadChecksums(t *testing.T) {
+	ts := newLocalServer(t)
+	content := "checksummed content"
+	sum := sha256.Sum256([]byte(content))
+	md := md5.Sum([]byte(content))
+
+	resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/sums/files/bad.txt", strings.NewReader(content),
+		map[string]string{checksum.SHA256Header: hex.EncodeToString(make([]byte, 32))})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, string(body))
+	assert.Equal(t, server.CodeChecksumMismatch, decodeAPI(t, body, nil).Code)
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets/sums/files/bad.txt/info", nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "a mismatching upload is not stored")
+
+	resp, body = apiRequest(t, ts, http.MethodPut, "/buckets/sums/files/bad.txt", strings.NewReader(content),
+		map[string]string{checksum.MD5Header: "not base64"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, string(body))
+
+	resp, body = apiRequest(t, ts, http.MethodPut, "/buckets/sums/files/good.txt", strings.NewReader(content), map[string]string{
+		checksum.MD5Header:    base64.StdEncoding.EncodeToString(md[:]),
+		checksum.SHA256Header: base64.StdEncoding.EncodeToString(sum[:]),
+	})
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var meta akavesdk.FileMeta
+	decodeAPI(t, body, &meta)
+	assert.Equal(t, hex.EncodeToString(sum[:]), meta.SHA256)
+	assert.NotEmpty(t, meta.CRC32C)
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/sums/files/good.txt/download", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, content, string(body))
+	assert.Equal(t, meta.SHA256, resp.Header.Get(checksum.SHA256Header))
+	assert.Equal(t, meta.CRC32C, resp.Header.Get(checksum.CRC32CHeader))
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/sums/files/good.txt/info", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var info akavesdk.FileMeta
+	decodeAPI(t, body, &info)
+	assert.Equal(t, meta.SHA256, info.SHA256)
+}
+
+// corruptStorage flips the first byte of every full download.
+type corruptStorage struct {
+	akavesdk.Storage
+}
+
+func (c corruptStorage) DownloadFile(ctx context.Context, bucketName, fileName string, w io.Writer) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(c.Storage.DownloadFile(ctx, bucketName, fileName, pw))
+	}()
+	b, err := io.ReadAll(pr)
+	if err != nil {
+		return err
+	}
+	b[0] ^= 0xff
+	_, err = w.Write(b)
+	return err
+}
+
+// TestServer_VerifyOnRead never delivers a complete download whose content
+// differs from the digest recorded at upload.
+func TestServer_VerifyOnRead(t *testing.T) {
+	srv, err := server.New(corruptStorage{akavesdk.NewMemoryStorage()}, server.Options{VerifyOnRead: true})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/sums/files/a.txt", strings.NewReader("hello world"), nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+	res, err := http.Get(ts.URL + "/buckets/sums/files/a.txt/download")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	got, err := io.ReadAll(res.Body)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Len(t, got, len("hello world")-1)
+}
+
+// TestChecksumStore_Log replays recorded and deleted entries from the log
+// and compacts it once most of its lines are dead.
+func TestChecksumStore_Log(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checksums.jsonl")
+	ref := checksum.FileRef{Tenant: "acme", Bucket: "b", Name: "a.txt"}
+	gone := checksum.FileRef{Bucket: "b", Name: "gone.txt"}
+	d := checksum.Digests{SHA256: "aa", CRC32C: "bb"}
+
+	s, err := checksum.OpenStore(path)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ref, "cid-1", d))
+	require.NoError(t, s.Put(gone, "cid-2", d))
+	require.NoError(t, s.Delete(gone))
+	require.NoError(t, s.Close())
+
+	s, err = checksum.OpenStore(path)
+	require.NoError(t, err)
+	got, ok := s.Get(ref, "cid-1")
+	assert.True(t, ok)
+	assert.Equal(t, d, got)
+	_, ok = s.Get(ref, "cid-other")
+	assert.False(t, ok, "digests are only reported for the content they were recorded for")
+	_, ok = s.Get(checksum.FileRef{Bucket: "b", Name: "a.txt"}, "cid-1")
+	assert.False(t, ok, "entries are kept per tenant")
+	_, ok = s.Get(gone, "cid-2")
+	assert.False(t, ok)
+
+	for i := 0; i < 3000; i++ {
+		require.NoError(t, s.Put(gone, fmt.Sprintf("cid-%d", i), d))
+	}
+	require.NoError(t, s.Close())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Less(t, strings.Count(string(b), "\n"), 2100, "the log is compacted")
+
+	// A line cut short by a crash is dropped on the next open.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"bucket":"b","file":"half`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	s, err = checksum.OpenStore(path)
+	require.NoError(t, err)
+	defer s.Close()
+	_, ok = s.Get(ref, "cid-1")
+	assert.True(t, ok)
+	_, ok = s.Get(gone, "cid-2999")
+	assert.True(t, ok)
+}
+
+// TestServer_ChecksumsDroppedOnDelete forgets the digests of deleted files.
+func TestServer_ChecksumsDroppedOnDelete(t *testing.T) {
+	store, err := checksum.OpenStore("")
+	require.NoError(t, err)
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{Checksums: store})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/sums/files/a.txt", strings.NewReader("hello"), nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var meta akavesdk.FileMeta
+	decodeAPI(t, body, &meta)
+	ref := checksum.FileRef{Bucket: "sums", Name: "a.txt"}
+	_, ok := store.Get(ref, meta.RootCID)
+	require.True(t, ok)
+
+	resp, body = apiRequest(t, ts, http.MethodDelete, "/buckets/sums/files/a.txt", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	_, ok = store.Get(ref, meta.RootCID)
+	assert.False(t, ok)
+}
+
+// TestS3Gateway_Checksums records the digests of objects stored through the
+// S3 gateway in the store shared with the REST API.
+func TestS3Gateway_Checksums(t *testing.T) {
+	storage := akavesdk.NewMemoryStorage()
+	store, err := checksum.OpenStore("")
+	require.NoError(t, err)
+	srv, err := server.New(storage, server.Options{Checksums: store})
+	require.NoError(t, err)
+	api := httptest.NewServer(srv.Handler())
+	t.Cleanup(api.Close)
+	gw := httptest.NewServer(s3.New(storage, s3.NewAuthenticator("us-east-1", awsExampleCreds), s3.Options{Checksums: store}))
+	t.Cleanup(gw.Close)
+
+	content := "object content"
+	sum := sha256.Sum256([]byte(content))
+	md := md5.Sum([]byte(content))
+
+	resp, body := s3Request(t, gw, http.MethodPut, "/sums", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	bad := md5.Sum([]byte("other content"))
+	resp, body = s3Request(t, gw, http.MethodPut, "/sums/bad.txt", strings.NewReader(content),
+		map[string]string{checksum.MD5Header: base64.StdEncoding.EncodeToString(bad[:])})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, string(body), "BadDigest")
+
+	resp, body = s3Request(t, gw, http.MethodPut, "/sums/a.txt", strings.NewReader(content),
+		map[string]string{checksum.MD5Header: base64.StdEncoding.EncodeToString(md[:])})
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	resp, body = apiRequest(t, api, http.MethodGet, "/buckets/sums/files/a.txt/info", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var info akavesdk.FileMeta
+	decodeAPI(t, body, &info)
+	assert.Equal(t, hex.EncodeToString(sum[:]), info.SHA256)
+	assert.NotEmpty(t, info.CRC32C)
+
+	resp, body = s3Request(t, gw, http.MethodDelete, "/sums/a.txt", nil, nil)
+	require.Equal(t, http.StatusNoContent, resp.StatusCode, string(body))
+	_, ok := store.Get(checksum.FileRef{Bucket: "sums", Name: "a.txt"}, info.RootCID)
+	assert.False(t, ok)
+}
@@ -0,0 +1,96 @@
+package test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/go-akavelink/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envMap returns a getenv function reading from m.
+func envMap(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func TestConfig_Precedence(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "akavelink.yaml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte(`
+listen: ":9000"
+akave:
+  node_address: node.example:5500
+  private_key: "0xfile"
+  max_concurrency: 4
+http:
+  read_timeout: 30s
+  max_upload_size: 1048576
+`), 0o600))
+
+	env := map[string]string{
+		config.FileEnv:          yamlFile,
+		"AKAVE_PRIVATE_KEY":     "0xenv",
+		"AKAVE_MAX_CONCURRENCY": "6",
+	}
+	cfg, err := config.Load([]string{"-akave.max-concurrency", "8", "-akave.use-connection-pool=false"}, envMap(env))
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9000", cfg.Listen, "file overrides default")
+	assert.Equal(t, "node.example:5500", cfg.Akave.NodeAddress)
+	assert.Equal(t, "0xenv", cfg.Akave.PrivateKey, "env overrides file")
+	assert.Equal(t, 8, cfg.Akave.MaxConcurrency, "flag overrides env")
+	assert.False(t, cfg.Akave.UseConnectionPool)
+	assert.Equal(t, 30*time.Second, cfg.HTTP.ReadTimeout)
+	assert.Equal(t, int64(1<<20), cfg.HTTP.MaxUploadSize)
+	assert.Equal(t, int64(1<<20), cfg.Akave.BlockPartSize, "untouched fields keep their default")
+
+	tomlFile := filepath.Join(dir, "akavelink.toml")
+	require.NoError(t, os.WriteFile(tomlFile, []byte(`
+[storage]
+backend = "local"
+
+[tus]
+dir = "/tmp/tus"
+expiration = "1h"
+`), 0o600))
+	cfg, err = config.Load([]string{"-config", tomlFile}, envMap(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "local", cfg.Storage.Backend)
+	assert.Equal(t, "/tmp/tus", cfg.Tus.Dir)
+	assert.Equal(t, time.Hour, cfg.Tus.Expiration)
+}
+
+func TestConfig_Errors(t *testing.T) {
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.yaml")
+	require.NoError(t, os.WriteFile(bad, []byte("akave:\n  node_adress: x\n"), 0o600))
+	_, err := config.Load([]string{"-config", bad}, envMap(nil))
+	assert.ErrorContains(t, err, "akave.node_adress: unknown setting")
+
+	_, err = config.Load(nil, envMap(map[string]string{"AKAVE_STORAGE": "local", "AKAVE_HTTP_READ_TIMEOUT": "soon"}))
+	assert.ErrorContains(t, err, "http.read_timeout: invalid duration")
+
+	_, err = config.Load([]string{"-storage.backend", "local", "-akave.retry.max-attempts", "0", "-akave.retry.max-backoff", "10ms"}, envMap(nil))
+	assert.ErrorContains(t, err, "akave.retry.max_attempts: must be at least 1")
+	assert.ErrorContains(t, err, "akave.retry.max_backoff: must not be less than")
+
+	_, err = config.Load([]string{"-akave.max-concurrency", "0"}, envMap(map[string]string{"AKAVE_S3_ADDRESS": ":9001"}))
+	require.Error(t, err)
+	var fields []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var fe *config.FieldError
+		require.True(t, errors.As(e, &fe))
+		fields = append(fields, fe.Field)
+	}
+	assert.Equal(t, []string{
+		"akave.node_address",
+		"akave.private_key",
+		"akave.max_concurrency",
+		"s3.access_key_id",
+		"s3.secret_access_key",
+	}, fields)
+}
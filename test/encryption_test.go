@@ -0,0 +1,116 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+func randomBytes(t *testing.T, n int) []byte {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	require.NoError(t, err)
+	return b
+}
+
+// TestEncryptedStorage_RoundTrip stores ciphertext in the wrapped storage
+// and returns the plaintext, in full and by range, with plaintext sizes.
+func TestEncryptedStorage_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	keys, err := akavesdk.NewKeyring(randomBytes(t, 32))
+	require.NoError(t, err)
+	inner := akavesdk.NewMemoryStorage()
+	st := akavesdk.NewEncryptedStorage(inner, keys)
+	_, err = st.CreateBucket(ctx, "secrets")
+	require.NoError(t, err)
+
+	const chunk = 64 << 10
+	for _, size := range []int{0, 1, chunk - 1, chunk, chunk + 1, 3*chunk + 100} {
+		name := "file-" + hex.EncodeToString([]byte{byte(size >> 16), byte(size >> 8), byte(size)})
+		plain := randomBytes(t, size)
+
+		meta, err := st.UploadFile(ctx, "secrets", name, bytes.NewReader(plain))
+		require.NoError(t, err)
+		assert.EqualValues(t, size, meta.Size)
+
+		stored, err := inner.FileInfo(ctx, "secrets", name)
+		require.NoError(t, err)
+		assert.Greater(t, stored.Size, int64(size), "the network stores the header and tags too")
+		var raw bytes.Buffer
+		require.NoError(t, inner.DownloadFile(ctx, "secrets", name, &raw))
+		if size > 16 {
+			assert.False(t, bytes.Contains(raw.Bytes(), plain[:16]), "plaintext must not be stored")
+		}
+
+		info, err := st.FileInfo(ctx, "secrets", name)
+		require.NoError(t, err)
+		assert.EqualValues(t, size, info.Size)
+
+		var got bytes.Buffer
+		require.NoError(t, st.DownloadFile(ctx, "secrets", name, &got))
+		assert.True(t, bytes.Equal(plain, got.Bytes()), "size %d", size)
+
+		for _, r := range [][2]int{{0, 1}, {chunk - 10, 20}, {size / 2, size / 3}, {size - 5, 5}} {
+			if r[0] < 0 || r[1] <= 0 || r[0]+r[1] > size {
+				continue
+			}
+			got.Reset()
+			require.NoError(t, st.DownloadRange(ctx, "secrets", name, int64(r[0]), int64(r[1]), &got))
+			assert.Equal(t, plain[r[0]:r[0]+r[1]], got.Bytes(), "size %d range %v", size, r)
+		}
+	}
+
+	list, err := st.ListFiles(ctx, "secrets", akavesdk.ListFilesOptions{Prefix: "file-030064"})
+	require.NoError(t, err)
+	require.Len(t, list.Files, 1)
+	assert.EqualValues(t, 3*chunk+100, list.Files[0].Size)
+
+	err = st.DownloadRange(ctx, "secrets", "file-000001", 0, 2, &bytes.Buffer{})
+	assert.ErrorIs(t, err, akavesdk.ErrInvalidArgument)
+}
+
+// TestEncryptedStorage_Keys rotates master keys and rejects files it
+// cannot decrypt.
+func TestEncryptedStorage_Keys(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := randomBytes(t, 32), randomBytes(t, 32)
+	path := filepath.Join(t.TempDir(), "master.keys")
+	require.NoError(t, os.WriteFile(path, []byte("# current key first\n"+hex.EncodeToString(oldKey)+"\n"), 0o600))
+
+	inner := akavesdk.NewMemoryStorage()
+	_, err := inner.CreateBucket(ctx, "secrets")
+	require.NoError(t, err)
+	keys, err := akavesdk.LoadKeyFile(path)
+	require.NoError(t, err)
+	_, err = akavesdk.NewEncryptedStorage(inner, keys).UploadFile(ctx, "secrets", "a.txt", bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+
+	rotated, err := akavesdk.NewKeyring(newKey, oldKey)
+	require.NoError(t, err)
+	var got bytes.Buffer
+	require.NoError(t, akavesdk.NewEncryptedStorage(inner, rotated).DownloadFile(ctx, "secrets", "a.txt", &got))
+	assert.Equal(t, "hello", got.String(), "retired keys still unwrap")
+
+	other, err := akavesdk.NewKeyring(newKey)
+	require.NoError(t, err)
+	err = akavesdk.NewEncryptedStorage(inner, other).DownloadFile(ctx, "secrets", "a.txt", &bytes.Buffer{})
+	assert.ErrorContains(t, err, "unknown master key")
+
+	_, err = inner.UploadFile(ctx, "secrets", "plain.txt", bytes.NewReader(bytes.Repeat([]byte("x"), 600)))
+	require.NoError(t, err)
+	err = akavesdk.NewEncryptedStorage(inner, keys).DownloadFile(ctx, "secrets", "plain.txt", &bytes.Buffer{})
+	assert.ErrorContains(t, err, "not encrypted")
+
+	require.NoError(t, os.WriteFile(path, []byte("too short\n"), 0o600))
+	_, err = akavesdk.LoadKeyFile(path)
+	assert.ErrorContains(t, err, "line 1")
+}
@@ -0,0 +1,45 @@
+package test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestClassify maps contract, SDK and gRPC failures to error kinds.
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		err  error
+		kind error
+	}{
+		{errors.New("sdk: BucketNonexists"), akavesdk.ErrBucketNotFound},
+		{errors.New("sdk: FileDoesNotExist"), akavesdk.ErrFileNotFound},
+		{errors.New("sdk: BucketAlreadyExists"), akavesdk.ErrAlreadyExists},
+		{errors.New("sdk: FileNameDuplicate"), akavesdk.ErrAlreadyExists},
+		{errors.New("sdk: BucketNonempty"), akavesdk.ErrBucketNotEmpty},
+		{errors.New("sdk: BucketInvalidOwner"), akavesdk.ErrPermissionDenied},
+		{errors.New("sdk: BucketInvalid"), akavesdk.ErrInvalidName},
+		{errors.New("sdk: invalid bucket name"), akavesdk.ErrInvalidName},
+		{errors.New("insufficient funds for gas * price + value"), akavesdk.ErrInsufficientFunds},
+		{errors.New("dial tcp 127.0.0.1:5500: connect: connection refused"), akavesdk.ErrNodeUnavailable},
+		{status.Error(codes.Unavailable, "transport is closing"), akavesdk.ErrNodeUnavailable},
+		{status.Error(codes.NotFound, "bucket does not exist"), akavesdk.ErrBucketNotFound},
+		{status.Error(codes.NotFound, "no such file"), akavesdk.ErrFileNotFound},
+	}
+	for _, tc := range cases {
+		err := akavesdk.Classify(fmt.Errorf("failed to do it: %w", tc.err))
+		assert.ErrorIs(t, err, tc.kind, tc.err.Error())
+		assert.ErrorIs(t, err, tc.err, "cause must stay reachable")
+		assert.Equal(t, "failed to do it: "+tc.err.Error(), err.Error())
+	}
+
+	plain := errors.New("something else")
+	assert.Same(t, plain, akavesdk.Classify(plain))
+	assert.Nil(t, akavesdk.KindOf(plain))
+	assert.NoError(t, akavesdk.Classify(nil))
+}
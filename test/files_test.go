@@ -0,0 +1,99 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleFiles() []akavesdk.File {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []akavesdk.File{
+		{Name: "logs/b.txt", Size: 300, CreatedAt: base.Add(2 * time.Hour)},
+		{Name: "logs/a.txt", Size: 100, CreatedAt: base.Add(3 * time.Hour)},
+		{Name: "img/c.png", Size: 200, CreatedAt: base.Add(1 * time.Hour)},
+		{Name: "logs/d.txt", Size: 100, CreatedAt: base},
+	}
+}
+
+func names(files []akavesdk.File) []string {
+	out := make([]string, len(files))
+	for i, f := range files {
+		out[i] = f.Name
+	}
+	return out
+}
+
+// TestPaginateFiles_PrefixAndSort checks prefix filtering and sort ordering.
+func TestPaginateFiles_PrefixAndSort(t *testing.T) {
+	page, err := akavesdk.PaginateFiles(sampleFiles(), akavesdk.ListFilesOptions{Prefix: "logs/"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"logs/a.txt", "logs/b.txt", "logs/d.txt"}, names(page.Files))
+	assert.Empty(t, page.NextCursor)
+
+	page, err = akavesdk.PaginateFiles(sampleFiles(), akavesdk.ListFilesOptions{SortBy: akavesdk.SortBySize})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"logs/a.txt", "logs/d.txt", "img/c.png", "logs/b.txt"}, names(page.Files))
+
+	page, err = akavesdk.PaginateFiles(sampleFiles(), akavesdk.ListFilesOptions{SortBy: akavesdk.SortByCreatedAt, Descending: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"logs/a.txt", "logs/b.txt", "img/c.png", "logs/d.txt"}, names(page.Files))
+}
+
+// TestPaginateFiles_Cursor walks every page and checks that nothing is skipped or repeated.
+func TestPaginateFiles_Cursor(t *testing.T) {
+	opts := akavesdk.ListFilesOptions{Limit: 2, SortBy: akavesdk.SortBySize}
+
+	var seen []string
+	for i := 0; i < 3; i++ {
+		page, err := akavesdk.PaginateFiles(sampleFiles(), opts)
+		require.NoError(t, err)
+		seen = append(seen, names(page.Files)...)
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"logs/a.txt", "logs/d.txt", "img/c.png", "logs/b.txt"}, seen)
+}
+
+// TestPaginateFiles_InvalidCursor ensures a malformed cursor is rejected.
+func TestPaginateFiles_InvalidCursor(t *testing.T) {
+	_, err := akavesdk.PaginateFiles(sampleFiles(), akavesdk.ListFilesOptions{Cursor: "%%%"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid cursor")
+}
+
+// TestParseFileSortKey covers the accepted sort keys and aliases.
+func TestParseFileSortKey(t *testing.T) {
+	key, err := akavesdk.ParseFileSortKey("committedAt")
+	require.NoError(t, err)
+	assert.Equal(t, akavesdk.SortByCreatedAt, key)
+
+	key, err = akavesdk.ParseFileSortKey("")
+	require.NoError(t, err)
+	assert.Equal(t, akavesdk.SortByName, key)
+
+	_, err = akavesdk.ParseFileSortKey("owner")
+	require.Error(t, err)
+}
+
+// TestFileMeta_Validators checks the ETag and modification time derived from file metadata.
+func TestFileMeta_Validators(t *testing.T) {
+	created := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	meta := akavesdk.FileMeta{RootCID: "bafyroot", CreatedAt: created}
+	assert.Equal(t, `"bafyroot"`, meta.ETag())
+	assert.Equal(t, created, meta.ModTime())
+
+	committed := created.Add(time.Minute)
+	meta.CommittedAt = &committed
+	assert.Equal(t, committed, meta.ModTime())
+
+	b, err := json.Marshal(akavesdk.FileMeta{RootCID: "bafyroot", CreatedAt: created})
+	require.NoError(t, err)
+	assert.NotContains(t, string(b), "committedAt", "an unknown commit time is omitted")
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode is a server.NodeChecker with scripted answers.
+type fakeNode struct {
+	pingErr error
+	balance int64
+	hang    bool
+}
+
+func (n *fakeNode) Ping(ctx context.Context) error {
+	if n.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return n.pingErr
+}
+
+func (n *fakeNode) Balance(context.Context) (*big.Int, error) {
+	return big.NewInt(n.balance), nil
+}
+
+type readinessReport struct {
+	Status string `json:"status"`
+	Checks map[string]struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"checks"`
+}
+
+func TestServer_Probes(t *testing.T) {
+	node := &fakeNode{balance: 100}
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{Readiness: server.ReadinessOptions{
+		Node:       node,
+		MinBalance: big.NewInt(50),
+		Timeout:    20 * time.Millisecond,
+	}})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	readyz := func() (int, readinessReport) {
+		resp, body := apiRequest(t, ts, http.MethodGet, "/readyz", nil, nil)
+		var report readinessReport
+		require.NoError(t, json.Unmarshal(body, &report), string(body))
+		return resp.StatusCode, report
+	}
+
+	status, report := readyz()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ready", report.Status)
+	for _, name := range []string{"shutdown", "node", "storage", "wallet_balance"} {
+		assert.Equal(t, "ok", report.Checks[name].Status, name)
+	}
+
+	node.balance = 10
+	node.pingErr = errors.New("connection refused")
+	status, report = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "not_ready", report.Status)
+	assert.Equal(t, "failed", report.Checks["node"].Status)
+	assert.Contains(t, report.Checks["node"].Error, "connection refused")
+	assert.Contains(t, report.Checks["wallet_balance"].Error, "below the minimum")
+	assert.Equal(t, "ok", report.Checks["storage"].Status)
+
+	node.pingErr, node.balance, node.hang = nil, 100, true
+	status, report = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Contains(t, report.Checks["node"].Error, "deadline exceeded", "checks are bounded by the timeout")
+
+	node.hang = false
+	srv.SetReady(false)
+	status, report = readyz()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "failed", report.Checks["shutdown"].Status)
+
+	resp, body := apiRequest(t, ts, http.MethodGet, "/livez", nil, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "liveness ignores dependencies and shutdown")
+	assert.Equal(t, "ok", string(body))
+}
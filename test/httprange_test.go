@@ -0,0 +1,54 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/go-akavelink/internal/httprange"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHTTPRange_Parse covers bounded, open-ended, suffix and multiple ranges.
+func TestHTTPRange_Parse(t *testing.T) {
+	ranges, err := httprange.Parse("bytes=0-99, 200-, -50", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, []httprange.Range{{Start: 0, Length: 100}, {Start: 200, Length: 800}, {Start: 950, Length: 50}}, ranges)
+	assert.Equal(t, "bytes 0-99/1000", ranges[0].ContentRange(1000))
+
+	ranges, err = httprange.Parse("bytes=900-5000", 1000)
+	require.NoError(t, err)
+	assert.Equal(t, []httprange.Range{{Start: 900, Length: 100}}, ranges)
+}
+
+// TestHTTPRange_ParseErrors distinguishes malformed from unsatisfiable ranges.
+func TestHTTPRange_ParseErrors(t *testing.T) {
+	for _, h := range []string{"items=0-1", "bytes=5-1", "bytes=abc", "bytes="} {
+		_, err := httprange.Parse(h, 1000)
+		assert.ErrorIs(t, err, httprange.ErrInvalid, h)
+	}
+
+	_, err := httprange.Parse("bytes=1000-", 1000)
+	assert.ErrorIs(t, err, httprange.ErrNoOverlap)
+	_, err = httprange.Parse("bytes=-0", 1000)
+	assert.ErrorIs(t, err, httprange.ErrNoOverlap)
+}
+
+// TestHTTPRange_IfRange checks entity tag and date validators.
+func TestHTTPRange_IfRange(t *testing.T) {
+	mod := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.True(t, httprange.IfRangeMatches(req, `"cid"`, mod))
+
+	req.Header.Set("If-Range", `"cid"`)
+	assert.True(t, httprange.IfRangeMatches(req, `"cid"`, mod))
+	req.Header.Set("If-Range", `W/"cid"`)
+	assert.False(t, httprange.IfRangeMatches(req, `"cid"`, mod))
+
+	req.Header.Set("If-Range", mod.Format(http.TimeFormat))
+	assert.True(t, httprange.IfRangeMatches(req, `"cid"`, mod))
+	req.Header.Set("If-Range", mod.Add(-time.Hour).Format(http.TimeFormat))
+	assert.False(t, httprange.IfRangeMatches(req, `"cid"`, mod))
+}
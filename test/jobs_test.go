@@ -0,0 +1,212 @@
+package test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/jobs"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/akave-ai/go-akavelink/internal/tenant"
+)
+
+// waitForJob polls get until the job has finished.
+func waitForJob(t *testing.T, get func() jobs.Job) jobs.Job {
+	t.Helper()
+	var job jobs.Job
+	require.Eventually(t, func() bool {
+		job = get()
+		return job.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+// TestServer_AsyncUpload answers uploads sent with "Prefer: respond-async"
+// with a job that commits them in the background.
+func TestServer_AsyncUpload(t *testing.T) {
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{JobsDir: t.TempDir()})
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.RunMaintenance(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	getJob := func(location string) func() jobs.Job {
+		return func() jobs.Job {
+			resp, body := apiRequest(t, ts, http.MethodGet, location, nil, nil)
+			require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+			var job jobs.Job
+			decodeAPI(t, body, &job)
+			return job
+		}
+	}
+	async := map[string]string{"Prefer": "respond-async"}
+
+	content := "uploaded in the background"
+	resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/jobs/files/a.txt", strings.NewReader(content), async)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, string(body))
+	assert.Equal(t, "respond-async", resp.Header.Get("Preference-Applied"))
+	var submitted jobs.Job
+	decodeAPI(t, body, &submitted)
+	assert.Equal(t, jobs.Queued, submitted.State)
+	assert.EqualValues(t, len(content), submitted.Size)
+	require.Equal(t, "/jobs/"+submitted.ID, resp.Header.Get("Location"))
+
+	job := waitForJob(t, getJob(resp.Header.Get("Location")))
+	require.Equal(t, jobs.Succeeded, job.State, job.Error)
+	assert.EqualValues(t, len(content), job.Progress)
+	require.NotNil(t, job.Result)
+	assert.NotEmpty(t, job.Result.RootCID)
+	assert.NotEmpty(t, job.Result.SHA256)
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/jobs/files/a.txt/download", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, content, string(body))
+
+	// Declared digests are checked when the job runs.
+	async[checksum.SHA256Header] = hex.EncodeToString(make([]byte, 32))
+	resp, body = apiRequest(t, ts, http.MethodPut, "/buckets/jobs/files/b.txt", strings.NewReader(content), async)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, string(body))
+	job = waitForJob(t, getJob(resp.Header.Get("Location")))
+	assert.Equal(t, jobs.Failed, job.State)
+	assert.Equal(t, server.CodeChecksumMismatch, job.Code)
+	assert.Contains(t, job.Error, "checksum mismatch")
+
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/jobs/"+strings.Repeat("0", 32), nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Without the preference uploads stay synchronous.
+	resp, body = apiRequest(t, ts, http.MethodPut, "/buckets/jobs/files/c.txt", strings.NewReader(content), nil)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+}
+
+// TestJobs_Resume runs jobs interrupted by a shutdown again after a restart.
+func TestJobs_Resume(t *testing.T) {
+	dir := t.TempDir()
+	started := make(chan struct{})
+	blocking := func(ctx context.Context, _ jobs.Job, body io.Reader) (akavesdk.FileMeta, error) {
+		io.CopyN(io.Discard, body, 3)
+		close(started)
+		<-ctx.Done()
+		return akavesdk.FileMeta{}, ctx.Err()
+	}
+	m, err := jobs.NewManager(jobs.Config{Dir: dir, Workers: 1}, blocking)
+	require.NoError(t, err)
+	submitted, err := m.Submit(jobs.Job{Bucket: "b", FileName: "a.txt"}, strings.NewReader("survives restarts"))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+	<-started
+	job, err := m.Get(submitted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Running, job.State)
+	assert.EqualValues(t, 3, job.Progress)
+	cancel()
+	<-done
+
+	var got string
+	m, err = jobs.NewManager(jobs.Config{Dir: dir}, func(_ context.Context, job jobs.Job, body io.Reader) (akavesdk.FileMeta, error) {
+		b, err := io.ReadAll(body)
+		got = string(b)
+		return akavesdk.FileMeta{Name: job.FileName, RootCID: "cid"}, err
+	})
+	require.NoError(t, err)
+	job, err = m.Get(submitted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Queued, job.State)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+	job = waitForJob(t, func() jobs.Job {
+		job, err := m.Get(submitted.ID)
+		require.NoError(t, err)
+		return job
+	})
+	assert.Equal(t, jobs.Succeeded, job.State)
+	assert.Equal(t, "survives restarts", got)
+	assert.Equal(t, "cid", job.Result.RootCID)
+
+	// Finished jobs survive restarts too.
+	m, err = jobs.NewManager(jobs.Config{Dir: dir}, nil)
+	require.NoError(t, err)
+	job, err = m.Get(submitted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, jobs.Succeeded, job.State)
+
+	_, err = m.Get("unknown")
+	assert.True(t, errors.Is(err, jobs.ErrNotFound))
+}
+
+// TestServer_JobVisibility shows a job only to the key that submitted it
+// and to admin keys of its tenant.
+func TestServer_JobVisibility(t *testing.T) {
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	create := func(name string, scope auth.Scope, tenantID string) string {
+		_, token, err := store.Create(auth.Key{Name: name, Scopes: []auth.Scope{scope}, Tenant: tenantID})
+		require.NoError(t, err)
+		return token
+	}
+	owner := create("owner", auth.ScopeFilesWrite, "team-a")
+	otherA := create("other-a", auth.ScopeFilesWrite, "team-a")
+	adminA := create("admin-a", auth.ScopeAdmin, "team-a")
+	adminB := create("admin-b", auth.ScopeAdmin, "team-b")
+	admin := create("admin", auth.ScopeAdmin, "")
+
+	pool := tenant.NewPool(newTestRegistry(t), (&countingFactory{}).open, 0)
+	defer pool.Close()
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{Keys: store, Tenants: pool, JobsDir: t.TempDir()})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+
+	headers := bearer(owner)
+	headers["Prefer"] = "respond-async"
+	resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/jobs/files/a.txt", strings.NewReader("hello"), headers)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode, string(body))
+	location := resp.Header.Get("Location")
+
+	for token, want := range map[string]int{
+		owner:  http.StatusOK,
+		adminA: http.StatusOK,
+		otherA: http.StatusNotFound,
+		adminB: http.StatusNotFound,
+		admin:  http.StatusNotFound,
+	} {
+		resp, body := apiRequest(t, ts, http.MethodGet, location, nil, bearer(token))
+		assert.Equal(t, want, resp.StatusCode, string(body))
+		if want == http.StatusNotFound {
+			assert.NotContains(t, string(body), "a.txt")
+		}
+	}
+}
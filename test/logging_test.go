@@ -0,0 +1,64 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/logging"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_RequestIDAndAccessLog(t *testing.T) {
+	var logs bytes.Buffer
+	logger, err := logging.New(&logs, "json", "info")
+	require.NoError(t, err)
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	key, token, err := store.Create(auth.Key{Name: "reader", Scopes: []auth.Scope{auth.ScopeBucketsRead}})
+	require.NoError(t, err)
+
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{Keys: store})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, body := apiRequest(t, ts, http.MethodGet, "/buckets/missing", nil, map[string]string{
+		"Authorization": "Bearer " + token,
+		"X-Request-ID":  "req-123",
+	})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "req-123", resp.Header.Get("X-Request-ID"))
+	assert.Equal(t, "req-123", decodeAPI(t, body, nil).RequestID)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &entry), logs.String())
+	assert.Equal(t, "request", entry["msg"])
+	assert.Equal(t, "req-123", entry["request_id"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/buckets/{bucket}", entry["route"])
+	assert.Equal(t, float64(http.StatusNotFound), entry["status"])
+	assert.Equal(t, key.ID, entry["key_id"])
+	assert.Contains(t, entry, "duration")
+	assert.Contains(t, entry, "bytes")
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets", nil, map[string]string{"X-Request-ID": "has spaces"})
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	generated := resp.Header.Get("X-Request-ID")
+	assert.Len(t, generated, 32, "unusable IDs are replaced")
+	assert.Equal(t, generated, decodeAPI(t, body, nil).RequestID)
+	assert.True(t, strings.Contains(logs.String(), generated))
+}
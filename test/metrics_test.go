@@ -0,0 +1,68 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape returns the samples exposed under /metrics keyed by metric name
+// and labels, e.g. `akavelink_active_transfers{direction="upload"}`.
+func scrape(t *testing.T, ts *httptest.Server) map[string]float64 {
+	t.Helper()
+	resp, body := apiRequest(t, ts, http.MethodGet, "/metrics", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	samples := make(map[string]float64)
+	sc := bufio.NewScanner(bytes.NewReader(body))
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		v, err := strconv.ParseFloat(line[i+1:], 64)
+		require.NoError(t, err, line)
+		samples[line[:i]] = v
+	}
+	return samples
+}
+
+func TestServer_Metrics(t *testing.T) {
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{Metrics: true})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	before := scrape(t, ts)
+
+	resp, _ := apiRequest(t, ts, http.MethodPut, "/buckets/metrics/files/a.txt", strings.NewReader("hello metrics"), nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets/metrics/files/a.txt/download", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets/metrics/files/missing.txt/info", nil, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/no/such/route", nil, nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	after := scrape(t, ts)
+	delta := func(name string) float64 { return after[name] - before[name] }
+
+	assert.Equal(t, 1.0, delta(`akavelink_http_requests_total{method="PUT",route="/buckets/{bucket}/files/{file:.+}",status="201"}`))
+	assert.Equal(t, 1.0, delta(`akavelink_http_requests_total{method="GET",route="/buckets/{bucket}/files/{file:.+}/info",status="404"}`))
+	assert.Equal(t, 1.0, delta(`akavelink_http_requests_total{method="GET",route="unmatched",status="404"}`))
+	assert.Equal(t, 1.0, delta(`akavelink_http_request_duration_seconds_count{method="PUT",route="/buckets/{bucket}/files/{file:.+}",status="201"}`))
+	assert.Equal(t, 13.0, delta(`akavelink_transfer_bytes_total{direction="upload"}`))
+	assert.Equal(t, 13.0, delta(`akavelink_transfer_bytes_total{direction="download"}`))
+	assert.Equal(t, 0.0, after[`akavelink_active_transfers{direction="upload"}`])
+	assert.Contains(t, after, "go_goroutines")
+}
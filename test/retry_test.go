@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+// TestRetryable separates errors proving a call had no effect from errors
+// that leave the outcome unknown.
+func TestRetryable(t *testing.T) {
+	cases := []struct {
+		err                 error
+		idempotent, mutates bool
+	}{
+		{errors.New("nonce too low: next nonce 7, tx nonce 6"), true, true},
+		{errors.New("replacement transaction underpriced"), true, true},
+		{status.Error(codes.Unavailable, "transport is closing"), true, true},
+		{errors.New("dial tcp 127.0.0.1:5500: connect: connection refused"), true, true},
+		{status.Error(codes.DeadlineExceeded, "deadline exceeded"), true, false},
+		{errors.New("read tcp: connection reset by peer"), true, false},
+		{status.Error(codes.NotFound, "bucket does not exist"), false, false},
+		{errors.New("sdk: BucketAlreadyExists"), false, false},
+		{context.Canceled, false, false},
+	}
+	for _, tc := range cases {
+		err := fmt.Errorf("sdk: %w", tc.err)
+		assert.Equal(t, tc.idempotent, akavesdk.Retryable(err, true), "idempotent: %v", tc.err)
+		assert.Equal(t, tc.mutates, akavesdk.Retryable(err, false), "mutating: %v", tc.err)
+	}
+}
+
+// TestRetryPolicy_Do retries transient failures until the call succeeds or
+// the attempts run out, and never retries permanent failures.
+func TestRetryPolicy_Do(t *testing.T) {
+	policy := akavesdk.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	ctx := context.Background()
+	unavailable := status.Error(codes.Unavailable, "node restarting")
+
+	calls := 0
+	err := policy.Do(ctx, "CreateBucket", false, func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return unavailable
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = policy.Do(ctx, "ViewBucket", true, func(context.Context) error {
+		calls++
+		return unavailable
+	})
+	assert.ErrorIs(t, err, unavailable)
+	assert.Equal(t, 3, calls, "gives up after MaxAttempts")
+
+	calls = 0
+	err = policy.Do(ctx, "DeleteFile", false, func(context.Context) error {
+		calls++
+		return status.Error(codes.DeadlineExceeded, "timeout")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, calls, "mutating calls with an unknown outcome are not retried")
+
+	calls = 0
+	err = policy.Do(ctx, "FileInfo", true, func(context.Context) error {
+		calls++
+		return status.Error(codes.NotFound, "no such file")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+// TestRetryPolicy_Deadline stops retrying when the context deadline would
+// expire before the next attempt.
+func TestRetryPolicy_Deadline(t *testing.T) {
+	policy := akavesdk.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Second}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	start := time.Now()
+	err := policy.Do(ctx, "ListBuckets", true, func(context.Context) error {
+		calls++
+		return status.Error(codes.Unavailable, "node restarting")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+}
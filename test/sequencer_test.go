@@ -0,0 +1,85 @@
+package test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+)
+
+// TestTxSequencer_Serializes never lets two transactions of a wallet be
+// sent at once.
+func TestTxSequencer_Serializes(t *testing.T) {
+	seq := akavesdk.NewTxSequencer()
+	var running, maxRunning atomic.Int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := seq.Do(context.Background(), func() error {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(2 * time.Millisecond)
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, maxRunning.Load())
+}
+
+// TestTxSequencer_ContextCancelled gives up waiting when the context ends.
+func TestTxSequencer_ContextCancelled(t *testing.T) {
+	seq := akavesdk.NewTxSequencer()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go seq.Do(context.Background(), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	called := false
+	err := seq.Do(ctx, func() error {
+		called = true
+		return nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, called)
+}
+
+// TestSequencerFor shares one sequencer per wallet.
+func TestSequencerFor(t *testing.T) {
+	const key = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+	a, err := akavesdk.SequencerFor(key)
+	require.NoError(t, err)
+	b, err := akavesdk.SequencerFor("0x" + key)
+	require.NoError(t, err)
+	assert.Same(t, a, b, "the 0x prefix does not change the wallet")
+
+	other, err := akavesdk.SequencerFor("6370fd033278c143179d81c5526140625662b8daa446c22ee2d73db3707e620c")
+	require.NoError(t, err)
+	assert.NotSame(t, a, other)
+
+	_, err = akavesdk.SequencerFor("not a key")
+	assert.ErrorContains(t, err, "invalid private key")
+}
@@ -0,0 +1,273 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// apiResponse mirrors the server's JSON envelope with the payload left raw.
+type apiResponse struct {
+	Success   bool            `json:"success"`
+	Data      json.RawMessage `json:"data"`
+	Error     string          `json:"error"`
+	Code      string          `json:"code"`
+	RequestID string          `json:"requestId"`
+}
+
+// newLocalServer runs the REST API against an in-memory storage backend.
+func newLocalServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func apiRequest(t *testing.T, ts *httptest.Server, method, path string, body io.Reader, headers map[string]string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, body)
+	require.NoError(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, b
+}
+
+func decodeAPI(t *testing.T, b []byte, data any) apiResponse {
+	t.Helper()
+	var env apiResponse
+	require.NoError(t, json.Unmarshal(b, &env))
+	if data != nil && len(env.Data) > 0 {
+		require.NoError(t, json.Unmarshal(env.Data, data))
+	}
+	return env
+}
+
+func TestServer_BucketLifecycle(t *testing.T) {
+	ts := newLocalServer(t)
+
+	resp, body := apiRequest(t, ts, http.MethodPost, "/buckets/photos", nil, nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var bucket akavesdk.Bucket
+	decodeAPI(t, body, &bucket)
+	assert.Equal(t, "photos", bucket.Name)
+
+	resp, body = apiRequest(t, ts, http.MethodPost, "/buckets/photos", nil, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	assert.Equal(t, server.CodeAlreadyExists, decodeAPI(t, body, nil).Code)
+
+	resp, body = apiRequest(t, ts, http.MethodPost, "/buckets/ab", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, server.CodeInvalidName, decodeAPI(t, body, nil).Code)
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var buckets []akavesdk.Bucket
+	decodeAPI(t, body, &buckets)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, "photos", buckets[0].Name)
+
+	resp, _ = apiRequest(t, ts, http.MethodPut, "/buckets/photos/files/a.txt", strings.NewReader("a"), nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, body = apiRequest(t, ts, http.MethodDelete, "/buckets/photos", nil, nil)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode, "non-empty bucket")
+	assert.Equal(t, server.CodeBucketNotEmpty, decodeAPI(t, body, nil).Code)
+
+	resp, _ = apiRequest(t, ts, http.MethodDelete, "/buckets/photos/files/a.txt", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodDelete, "/buckets/photos", nil, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/photos", nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	env := decodeAPI(t, body, nil)
+	assert.False(t, env.Success)
+	assert.Equal(t, server.CodeBucketNotFound, env.Code)
+}
+
+func TestServer_UploadAndDownload(t *testing.T) {
+	ts := newLocalServer(t)
+	content := "0123456789abcdefghij"
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, err := mw.CreateFormFile("file", "readme.txt")
+	require.NoError(t, err)
+	_, err = io.WriteString(fw, content)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	// The bucket does not exist yet and is created by the upload.
+	resp, body := apiRequest(t, ts, http.MethodPost, "/files/upload/notes", &form, map[string]string{"Content-Type": mw.FormDataContentType()})
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var meta akavesdk.FileMeta
+	decodeAPI(t, body, &meta)
+	assert.Equal(t, int64(len(content)), meta.Size)
+	assert.NotEmpty(t, meta.RootCID)
+
+	resp, body = apiRequest(t, ts, http.MethodPost, "/files/upload/notes", strings.NewReader("x"), map[string]string{"Content-Type": "text/plain"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, string(body))
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/files/download/notes/readme.txt", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, content, string(body))
+	assert.Equal(t, meta.ETag(), resp.Header.Get("ETag"))
+	assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/notes/files/readme.txt/download", nil, map[string]string{"Range": "bytes=5-9"})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "56789", string(body))
+	assert.Equal(t, "bytes 5-9/20", resp.Header.Get("Content-Range"))
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/notes/files/readme.txt/download", nil, map[string]string{"Range": "bytes=0-1,-2"})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "multipart/byteranges"))
+	assert.Contains(t, string(body), "01")
+	assert.Contains(t, string(body), "ij")
+
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets/notes/files/readme.txt/download", nil, map[string]string{"Range": "bytes=100-"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, resp.StatusCode)
+	assert.Equal(t, "bytes */20", resp.Header.Get("Content-Range"))
+
+	resp, _ = apiRequest(t, ts, http.MethodHead, "/buckets/notes/files/readme.txt/download", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "20", resp.Header.Get("Content-Length"))
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/notes/files/readme.txt/info", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var info akavesdk.FileMeta
+	decodeAPI(t, body, &info)
+	assert.Equal(t, meta.RootCID, info.RootCID)
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/notes/files/missing.txt/info", nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, server.CodeFileNotFound, decodeAPI(t, body, nil).Code)
+}
+
+func TestServer_ListAndBulkDelete(t *testing.T) {
+	ts := newLocalServer(t)
+	for _, name := range []string{"a.txt", "b.txt", "logs/c.txt"} {
+		resp, _ := apiRequest(t, ts, http.MethodPut, "/buckets/store/files/"+name, strings.NewReader(name), nil)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+	}
+
+	resp, body := apiRequest(t, ts, http.MethodGet, "/buckets/store/files?limit=2", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var page akavesdk.FileList
+	decodeAPI(t, body, &page)
+	assert.Equal(t, []string{"a.txt", "b.txt"}, names(page.Files))
+	require.NotEmpty(t, page.NextCursor)
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/buckets/store/files?limit=2&cursor="+page.NextCursor, nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	page = akavesdk.FileList{}
+	decodeAPI(t, body, &page)
+	assert.Equal(t, []string{"logs/c.txt"}, names(page.Files))
+	assert.Empty(t, page.NextCursor)
+
+	resp, body = apiRequest(t, ts, http.MethodPost, "/buckets/store/delete", strings.NewReader(`{"files":["a.txt","missing.txt","logs/c.txt"]}`), nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+	var result struct {
+		Deleted int `json:"deleted"`
+		Failed  int `json:"failed"`
+	}
+	decodeAPI(t, body, &result)
+	assert.Equal(t, 2, result.Deleted)
+	assert.Equal(t, 1, result.Failed)
+}
+
+func TestServer_MaxUploadSize(t *testing.T) {
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{MaxUploadSize: 8})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, _ := apiRequest(t, ts, http.MethodPut, "/buckets/store/files/small.txt", strings.NewReader("12345678"), nil)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/store/files/big.txt", strings.NewReader("123456789"), nil)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Equal(t, "REQUEST_ENTITY_TOO_LARGE", decodeAPI(t, body, nil).Code)
+}
+
+func TestDiskStorage_Persists(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	st, err := akavesdk.NewDiskStorage(dir)
+	require.NoError(t, err)
+	_, err = st.CreateBucket(ctx, "archive")
+	require.NoError(t, err)
+	meta, err := st.UploadFile(ctx, "archive", "../escape.bin", strings.NewReader("persisted"))
+	require.NoError(t, err)
+
+	_, err = st.UploadFile(ctx, "archive", "../escape.bin", strings.NewReader("again"))
+	assert.ErrorIs(t, err, akavesdk.ErrAlreadyExists)
+
+	reopened, err := akavesdk.NewDiskStorage(dir)
+	require.NoError(t, err)
+	info, err := reopened.FileInfo(ctx, "archive", "../escape.bin")
+	require.NoError(t, err)
+	assert.Equal(t, meta.RootCID, info.RootCID)
+
+	var buf bytes.Buffer
+	require.NoError(t, reopened.DownloadRange(ctx, "archive", "../escape.bin", 2, 4, &buf))
+	assert.Equal(t, "rsis", buf.String())
+}
+
+func TestServer_DrainWaitsForTransfers(t *testing.T) {
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	body, upload := io.Pipe()
+	done := make(chan int)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/buckets/store/files/slow.bin", body)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	_, err = upload.Write([]byte("first half"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return srv.InFlight() == 1 }, time.Second, time.Millisecond)
+
+	srv.SetReady(false)
+	resp, _ := apiRequest(t, ts, http.MethodGet, "/health", nil, nil)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, srv.Wait(ctx), context.DeadlineExceeded, "the upload is still running")
+
+	_, err = upload.Write([]byte(" second half"))
+	require.NoError(t, err)
+	require.NoError(t, upload.Close())
+	assert.Equal(t, http.StatusCreated, <-done)
+	require.NoError(t, srv.Wait(context.Background()))
+	assert.Equal(t, 0, srv.InFlight())
+}
@@ -0,0 +1,255 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/akave-ai/go-akavelink/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingFactory opens one in-memory storage per call and counts the calls.
+type countingFactory struct {
+	opened atomic.Int32
+}
+
+func (f *countingFactory) open(tenant.Tenant) (akavesdk.Storage, error) {
+	f.opened.Add(1)
+	return akavesdk.NewMemoryStorage(), nil
+}
+
+func newTestRegistry(t *testing.T) *tenant.Registry {
+	t.Helper()
+	t.Setenv("TEAM_B_KEY", "0xbbbb")
+	reg, err := tenant.NewRegistry(
+		tenant.Tenant{ID: "team-a", PrivateKey: "0xaaaa"},
+		tenant.Tenant{ID: "team-b", PrivateKeyEnv: "TEAM_B_KEY"},
+	)
+	require.NoError(t, err)
+	return reg
+}
+
+func TestTenantRegistry_Validation(t *testing.T) {
+	_, err := tenant.NewRegistry(tenant.Tenant{ID: "x"})
+	assert.ErrorContains(t, err, "no private key")
+
+	_, err = tenant.NewRegistry(tenant.Tenant{ID: "x", PrivateKey: "k"}, tenant.Tenant{ID: "x", PrivateKey: "k"})
+	assert.ErrorContains(t, err, "duplicate")
+
+	reg := newTestRegistry(t)
+	tb, err := reg.Lookup("team-b")
+	require.NoError(t, err)
+	assert.Equal(t, "0xbbbb", tb.PrivateKey)
+	_, err = reg.Lookup("team-c")
+	assert.ErrorIs(t, err, tenant.ErrUnknownTenant)
+}
+
+func TestTenantPool_LazyCachedAndEvicted(t *testing.T) {
+	var factory countingFactory
+	pool := tenant.NewPool(newTestRegistry(t), factory.open, time.Millisecond)
+	defer pool.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, release, err := pool.Acquire("team-a")
+			if assert.NoError(t, err) {
+				release()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), factory.opened.Load(), "concurrent acquires share one client")
+
+	_, release, err := pool.Acquire("team-b")
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 1, pool.Evict(), "only the idle team-a client is closed")
+	assert.Equal(t, 1, pool.Len())
+
+	release()
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 1, pool.Evict())
+	assert.Equal(t, 0, pool.Len())
+
+	_, release, err = pool.Acquire("team-a")
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, int32(3), factory.opened.Load(), "evicted clients are reopened on demand")
+
+	_, _, err = pool.Acquire("team-c")
+	assert.ErrorIs(t, err, tenant.ErrUnknownTenant)
+}
+
+func TestServer_TenantIsolation(t *testing.T) {
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	_, admin, err := store.Create(auth.Key{Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	require.NoError(t, err)
+	_, keyA, err := store.Create(auth.Key{Name: "a", Scopes: []auth.Scope{auth.ScopeAdmin}, Tenant: "team-a"})
+	require.NoError(t, err)
+	_, keyB, err := store.Create(auth.Key{Name: "b", Scopes: []auth.Scope{auth.ScopeAdmin}, Tenant: "team-b"})
+	require.NoError(t, err)
+
+	var factory countingFactory
+	pool := tenant.NewPool(newTestRegistry(t), factory.open, 0)
+	defer pool.Close()
+
+	srv, err := server.New(nil, server.Options{Keys: store, Tenants: pool})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+
+	resp, _ := apiRequest(t, ts, http.MethodPost, "/buckets/shared-name", nil, bearer(keyA))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodPost, "/buckets/shared-name", nil, bearer(keyB))
+	assert.Equal(t, http.StatusCreated, resp.StatusCode, "tenants have separate bucket namespaces")
+
+	resp, _ = apiRequest(t, ts, http.MethodPost, "/buckets/only-a", nil, bearer(keyA))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, body := apiRequest(t, ts, http.MethodGet, "/buckets", nil, bearer(keyB))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var buckets []akavesdk.Bucket
+	decodeAPI(t, body, &buckets)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, "shared-name", buckets[0].Name)
+
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets", nil, bearer(admin))
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "no default wallet configured")
+
+	resp, body = apiRequest(t, ts, http.MethodPost, "/admin/keys", strings.NewReader(`{"name":"c","scopes":["admin"],"tenant":"team-c"}`), bearer(admin))
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, string(body))
+	assert.Equal(t, int32(2), factory.opened.Load())
+}
+
+func TestServer_TenantTusUploads(t *testing.T) {
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	_, keyA, err := store.Create(auth.Key{Name: "a", Scopes: []auth.Scope{auth.ScopeAdmin}, Tenant: "team-a"})
+	require.NoError(t, err)
+	_, otherA, err := store.Create(auth.Key{Name: "a2", Scopes: []auth.Scope{auth.ScopeFilesWrite}, Tenant: "team-a"})
+	require.NoError(t, err)
+	_, keyB, err := store.Create(auth.Key{Name: "b", Scopes: []auth.Scope{auth.ScopeAdmin}, Tenant: "team-b"})
+	require.NoError(t, err)
+
+	var factory countingFactory
+	pool := tenant.NewPool(newTestRegistry(t), factory.open, 0)
+	defer pool.Close()
+
+	srv, err := server.New(nil, server.Options{Keys: store, Tenants: pool, TusDir: t.TempDir()})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	as := func(token string, headers map[string]string) map[string]string {
+		out := map[string]string{"Authorization": "Bearer " + token}
+		for k, v := range headers {
+			out[k] = v
+		}
+		return out
+	}
+	patch := map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": "0"}
+
+	resp := tusRequest(t, ts, http.MethodPost, "/uploads", nil, as(keyA, map[string]string{
+		"Upload-Length":   "5",
+		"Upload-Metadata": tusMetadata("docs", "a.txt"),
+	}))
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	location := resp.Header.Get("Location")
+
+	// Keys of another tenant, or other non-admin keys of the same tenant,
+	// cannot see, resume or terminate the upload.
+	for _, token := range []string{keyB, otherA} {
+		resp = tusRequest(t, ts, http.MethodHead, location, nil, as(token, nil))
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp = tusRequest(t, ts, http.MethodPatch, location, []byte("hello"), as(token, patch))
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp = tusRequest(t, ts, http.MethodDelete, location, nil, as(token, nil))
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+
+	resp = tusRequest(t, ts, http.MethodHead, location, nil, as(keyA, nil))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get("Upload-Offset"))
+	resp = tusRequest(t, ts, http.MethodPatch, location, []byte("hello"), as(keyA, patch))
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// The upload is committed with the creating tenant's storage.
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets/docs/files/a.txt/info", nil, as(keyA, nil))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodGet, "/buckets/docs/files/a.txt/info", nil, as(keyB, nil))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestServer_TenantKeyManagement confines admin keys of a tenant to the
+// keys of that tenant.
+func TestServer_TenantKeyManagement(t *testing.T) {
+	store, err := auth.OpenStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	_, admin, err := store.Create(auth.Key{Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	require.NoError(t, err)
+	_, adminA, err := store.Create(auth.Key{Name: "a", Scopes: []auth.Scope{auth.ScopeAdmin}, Tenant: "team-a"})
+	require.NoError(t, err)
+	b, _, err := store.Create(auth.Key{Name: "b", Scopes: []auth.Scope{auth.ScopeFilesRead}, Tenant: "team-b"})
+	require.NoError(t, err)
+
+	pool := tenant.NewPool(newTestRegistry(t), (&countingFactory{}).open, 0)
+	defer pool.Close()
+	srv, err := server.New(nil, server.Options{Keys: store, Tenants: pool})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+
+	for _, tenantID := range []string{"team-b", "team-c"} {
+		resp, body := apiRequest(t, ts, http.MethodPost, "/admin/keys",
+			strings.NewReader(`{"name":"x","scopes":["admin"],"tenant":"`+tenantID+`"}`), bearer(adminA))
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode, string(body))
+	}
+
+	resp, body := apiRequest(t, ts, http.MethodPost, "/admin/keys", strings.NewReader(`{"name":"a2","scopes":["admin"]}`), bearer(adminA))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var created auth.Key
+	decodeAPI(t, body, &created)
+	assert.Equal(t, "team-a", created.Tenant, "keys created by a tenant admin belong to its tenant")
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/admin/keys", nil, bearer(adminA))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var keys []auth.Key
+	decodeAPI(t, body, &keys)
+	require.Len(t, keys, 2)
+	for _, k := range keys {
+		assert.Equal(t, "team-a", k.Tenant)
+	}
+
+	resp, _ = apiRequest(t, ts, http.MethodDelete, "/admin/keys/"+b.ID, nil, bearer(adminA))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	_, err = store.Get(b.ID)
+	assert.NoError(t, err, "keys of other tenants are not revoked")
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/admin/keys", nil, bearer(admin))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	decodeAPI(t, body, &keys)
+	assert.Len(t, keys, 4, "admin keys without a tenant manage every key")
+	resp, _ = apiRequest(t, ts, http.MethodDelete, "/admin/keys/"+b.ID, nil, bearer(admin))
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
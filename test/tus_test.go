@@ -0,0 +1,156 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/tus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingCommit captures what the tus handler commits instead of talking to Akave.
+type recordingCommit struct {
+	bucket, file string
+	data         []byte
+}
+
+func (c *recordingCommit) commit(_ context.Context, bucket, fileName string, r io.Reader) (akavesdk.FileMeta, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return akavesdk.FileMeta{}, err
+	}
+	c.bucket, c.file, c.data = bucket, fileName, data
+	return akavesdk.FileMeta{RootCID: "bafytest", BucketName: bucket, Name: fileName, Size: int64(len(data))}, nil
+}
+
+func tusRequest(t *testing.T, srv *httptest.Server, method, path string, body []byte, headers map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Tus-Resumable", tus.Version)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp
+}
+
+func tusMetadata(bucket, file string) string {
+	return "bucket " + base64.StdEncoding.EncodeToString([]byte(bucket)) +
+		",filename " + base64.StdEncoding.EncodeToString([]byte(file))
+}
+
+// TestTus_ResumableUpload creates an upload, sends it in two PATCH requests and checks the commit.
+func TestTus_ResumableUpload(t *testing.T) {
+	rec := &recordingCommit{}
+	h, err := tus.NewHandler(tus.Config{Dir: t.TempDir(), BasePath: "/uploads"}, rec.commit)
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	payload := []byte(strings.Repeat("akave", 100))
+
+	resp := tusRequest(t, srv, http.MethodPost, "/uploads", nil, map[string]string{
+		"Upload-Length":   "500",
+		"Upload-Metadata": tusMetadata("my-bucket", "data.bin"),
+	})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	location := resp.Header.Get("Location")
+	require.True(t, strings.HasPrefix(location, "/uploads/"))
+
+	patch := func(offset string, body []byte) *http.Response {
+		return tusRequest(t, srv, http.MethodPatch, location, body, map[string]string{
+			"Content-Type":  "application/offset+octet-stream",
+			"Upload-Offset": offset,
+		})
+	}
+
+	resp = patch("0", payload[:200])
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "200", resp.Header.Get("Upload-Offset"))
+
+	// A stale offset is rejected with the current one.
+	resp = patch("0", payload[:200])
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	resp = tusRequest(t, srv, http.MethodHead, location, nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "200", resp.Header.Get("Upload-Offset"))
+	assert.Equal(t, "500", resp.Header.Get("Upload-Length"))
+
+	resp = patch("200", payload[200:])
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "500", resp.Header.Get("Upload-Offset"))
+	assert.Equal(t, "bafytest", resp.Header.Get("Akave-Root-CID"))
+
+	assert.Equal(t, "my-bucket", rec.bucket)
+	assert.Equal(t, "data.bin", rec.file)
+	assert.Equal(t, payload, rec.data)
+}
+
+// TestTus_Termination checks that a terminated upload is gone.
+func TestTus_Termination(t *testing.T) {
+	h, err := tus.NewHandler(tus.Config{Dir: t.TempDir(), BasePath: "/uploads"}, (&recordingCommit{}).commit)
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp := tusRequest(t, srv, http.MethodPost, "/uploads", nil, map[string]string{
+		"Upload-Length":   "10",
+		"Upload-Metadata": tusMetadata("my-bucket", "data.bin"),
+	})
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	location := resp.Header.Get("Location")
+
+	resp = tusRequest(t, srv, http.MethodDelete, location, nil, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = tusRequest(t, srv, http.MethodHead, location, nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestTus_ProtocolChecks covers discovery and required headers.
+func TestTus_ProtocolChecks(t *testing.T) {
+	h, err := tus.NewHandler(tus.Config{Dir: t.TempDir(), BasePath: "/uploads", MaxSize: 100}, (&recordingCommit{}).commit)
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp := tusRequest(t, srv, http.MethodOptions, "/uploads", nil, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Tus-Extension"), "termination")
+	assert.Equal(t, "100", resp.Header.Get("Tus-Max-Size"))
+
+	resp = tusRequest(t, srv, http.MethodPost, "/uploads", nil, map[string]string{
+		"Upload-Length":   "1000",
+		"Upload-Metadata": tusMetadata("my-bucket", "data.bin"),
+	})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+	resp = tusRequest(t, srv, http.MethodPost, "/uploads", nil, map[string]string{"Upload-Length": "10"})
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	patch := map[string]string{"Content-Type": "application/offset+octet-stream", "Upload-Offset": "0"}
+	for _, id := range []string{"abc", strings.Repeat("0f", 16)} {
+		for _, method := range []string{http.MethodHead, http.MethodPatch, http.MethodDelete} {
+			resp = tusRequest(t, srv, method, "/uploads/"+id, nil, patch)
+			assert.Equal(t, http.StatusNotFound, resp.StatusCode, "%s %s", method, id)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodHead, srv.URL+"/uploads/abc", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+}
@@ -0,0 +1,404 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/akave-ai/go-akavelink/internal/auth"
+	"github.com/akave-ai/go-akavelink/internal/checksum"
+	"github.com/akave-ai/go-akavelink/internal/s3"
+	akavesdk "github.com/akave-ai/go-akavelink/internal/sdk"
+	"github.com/akave-ai/go-akavelink/internal/server"
+	"github.com/akave-ai/go-akavelink/internal/tenant"
+	"github.com/akave-ai/go-akavelink/internal/webhook"
+)
+
+// webhookReceiver collects the events delivered to it, answering with the
+// status returned by respond.
+type webhookReceiver struct {
+	*httptest.Server
+	secret  string
+	respond func(attempt int) int
+
+	mu       sync.Mutex
+	attempts int
+	events   []webhook.Event
+	ids      []string
+}
+
+func newWebhookReceiver(t *testing.T, secret string, respond func(attempt int) int) *webhookReceiver {
+	t.Helper()
+	rcv := &webhookReceiver{secret: secret, respond: respond}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		assert.NoError(t, webhook.Verify(rcv.secret, r.Header.Get(webhook.SignatureHeader), body, time.Now(), time.Minute))
+		rcv.attempts++
+		rcv.ids = append(rcv.ids, r.Header.Get(webhook.DeliveryHeader))
+		status := http.StatusNoContent
+		if rcv.respond != nil {
+			status = rcv.respond(rcv.attempts)
+		}
+		if status < 300 {
+			var ev webhook.Event
+			require.NoError(t, json.Unmarshal(body, &ev))
+			assert.Equal(t, string(ev.Type), r.Header.Get(webhook.EventHeader))
+			rcv.events = append(rcv.events, ev)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (rcv *webhookReceiver) received() []webhook.Event {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]webhook.Event(nil), rcv.events...)
+}
+
+// runDispatcher runs d until the test ends.
+func runDispatcher(t *testing.T, d *webhook.Dispatcher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestWebhook_Signature(t *testing.T) {
+	body := []byte(`{"type":"bucket.created"}`)
+	now := time.Now()
+	sig := webhook.Sign("secret", now, body)
+	assert.NoError(t, webhook.Verify("secret", sig, body, now, time.Minute))
+	assert.ErrorIs(t, webhook.Verify("other", sig, body, now, time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("secret", sig, []byte(`{}`), now, time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("secret", sig, body, now.Add(time.Hour), time.Minute), webhook.ErrInvalidSignature)
+	assert.ErrorIs(t, webhook.Verify("secret", "garbage", body, now, time.Minute), webhook.ErrInvalidSignature)
+}
+
+func TestWebhook_Store(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	subs, err := webhook.OpenStore(path)
+	require.NoError(t, err)
+
+	_, err = subs.Create(webhook.Subscription{URL: "ftp://example.com"})
+	assert.ErrorIs(t, err, webhook.ErrInvalidSubscription)
+	_, err = subs.Create(webhook.Subscription{URL: "https://example.com", Events: []webhook.EventType{"file.renamed"}})
+	assert.ErrorIs(t, err, webhook.ErrInvalidSubscription)
+
+	sub, err := subs.Create(webhook.Subscription{URL: "https://example.com/hook", Events: []webhook.EventType{webhook.FileUploaded}})
+	require.NoError(t, err)
+	assert.NotEmpty(t, sub.Secret)
+	assert.True(t, sub.Wants(webhook.FileUploaded))
+	assert.False(t, sub.Wants(webhook.BucketCreated))
+
+	reopened, err := webhook.OpenStore(path)
+	require.NoError(t, err)
+	list := reopened.List()
+	require.Len(t, list, 1)
+	assert.Equal(t, sub.ID, list[0].ID)
+	assert.Empty(t, list[0].Secret, "secrets are not listed")
+
+	require.NoError(t, reopened.Delete(sub.ID))
+	assert.ErrorIs(t, reopened.Delete(sub.ID), webhook.ErrSubscriptionNotFound)
+}
+
+// TestWebhook_Retry retries failed deliveries with the same delivery ID and
+// dead letters those that keep failing.
+func TestWebhook_Retry(t *testing.T) {
+	dir := t.TempDir()
+	subs, err := webhook.OpenStore(filepath.Join(dir, "webhooks.json"))
+	require.NoError(t, err)
+	deadLetters := filepath.Join(dir, "dead-letters.jsonl")
+	d := webhook.NewDispatcher(webhook.Config{
+		Subscriptions:  subs,
+		DeadLetterPath: deadLetters,
+		MaxAttempts:    3,
+		InitialBackoff: 5 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+	})
+	runDispatcher(t, d)
+
+	flaky := newWebhookReceiver(t, "flaky-secret", func(attempt int) int {
+		if attempt < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	_, err = subs.Create(webhook.Subscription{URL: flaky.URL, Secret: flaky.secret})
+	require.NoError(t, err)
+	var down atomic.Int32
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		down.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(broken.Close)
+	dead, err := subs.Create(webhook.Subscription{URL: broken.URL})
+	require.NoError(t, err)
+
+	d.Emit(webhook.Event{Type: webhook.BucketCreated, Bucket: "b"})
+
+	require.Eventually(t, func() bool { return len(flaky.received()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	flaky.mu.Lock()
+	assert.Equal(t, 3, flaky.attempts)
+	assert.Equal(t, flaky.ids[0], flaky.ids[2], "retries keep the delivery ID")
+	flaky.mu.Unlock()
+
+	var entry webhook.DeadLetter
+	require.Eventually(t, func() bool {
+		f, err := os.Open(deadLetters)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		return sc.Scan() && json.Unmarshal(sc.Bytes(), &entry) == nil
+	}, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, dead.ID, entry.Subscription)
+	assert.Equal(t, 3, entry.Attempts)
+	assert.EqualValues(t, 3, down.Load())
+	assert.Contains(t, entry.Error, "500")
+	var ev webhook.Event
+	require.NoError(t, json.Unmarshal(entry.Event, &ev))
+	assert.Equal(t, webhook.BucketCreated, ev.Type)
+}
+
+// TestServer_Webhooks manages subscriptions through the admin API and
+// emits events from the bucket and file handlers.
+func TestServer_Webhooks(t *testing.T) {
+	subs, err := webhook.OpenStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	require.NoError(t, err)
+	d := webhook.NewDispatcher(webhook.Config{Subscriptions: subs})
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{Webhooks: d})
+	require.NoError(t, err)
+	runDispatcher(t, d)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+
+	resp, body := apiRequest(t, ts, http.MethodPost, "/admin/webhooks", strings.NewReader(`{"url":"not a url"}`), nil)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, string(body))
+
+	rcv := newWebhookReceiver(t, "", nil)
+	resp, body = apiRequest(t, ts, http.MethodPost, "/admin/webhooks", strings.NewReader(`{"url":"`+rcv.URL+`"}`), nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	var sub webhook.Subscription
+	decodeAPI(t, body, &sub)
+	require.NotEmpty(t, sub.Secret)
+	rcv.mu.Lock()
+	rcv.secret = sub.Secret
+	rcv.mu.Unlock()
+
+	uploadsOnly := newWebhookReceiver(t, "uploads-secret", nil)
+	resp, body = apiRequest(t, ts, http.MethodPost, "/admin/webhooks",
+		strings.NewReader(`{"url":"`+uploadsOnly.URL+`","events":["file.uploaded"],"secret":"uploads-secret"}`), nil)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/admin/webhooks", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list []webhook.Subscription
+	decodeAPI(t, body, &list)
+	require.Len(t, list, 2)
+	assert.Empty(t, list[0].Secret)
+
+	steps := []struct{ method, path, body string }{
+		{http.MethodPost, "/buckets/hooks", ""},
+		{http.MethodPut, "/buckets/hooks/files/a.txt", "hello"},
+		{http.MethodDelete, "/buckets/hooks/files/a.txt", ""},
+		{http.MethodDelete, "/buckets/hooks", ""},
+	}
+	for _, step := range steps {
+		resp, body := apiRequest(t, ts, step.method, step.path, strings.NewReader(step.body), nil)
+		require.Less(t, resp.StatusCode, 300, "%s %s: %s", step.method, step.path, body)
+	}
+	resp, _ = apiRequest(t, ts, http.MethodPut, "/buckets/other/files/bad.txt", strings.NewReader("hello"),
+		map[string]string{checksum.SHA256Header: strings.Repeat("00", 32)})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	want := []webhook.EventType{
+		webhook.BucketCreated, webhook.FileUploaded, webhook.FileDeleted, webhook.BucketDeleted,
+		webhook.BucketCreated, webhook.FileFailed,
+	}
+	require.Eventually(t, func() bool { return len(rcv.received()) == len(want) }, 5*time.Second, 5*time.Millisecond)
+	got := make(map[webhook.EventType]webhook.Event)
+	var types []webhook.EventType
+	for _, ev := range rcv.received() {
+		types = append(types, ev.Type)
+		got[ev.Type] = ev
+	}
+	assert.ElementsMatch(t, want, types)
+	require.NotNil(t, got[webhook.FileUploaded].FileMeta)
+	assert.NotEmpty(t, got[webhook.FileUploaded].FileMeta.RootCID)
+	assert.Equal(t, "a.txt", got[webhook.FileDeleted].File)
+	assert.Equal(t, server.CodeChecksumMismatch, got[webhook.FileFailed].Code)
+
+	require.Eventually(t, func() bool { return len(uploadsOnly.received()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, webhook.FileUploaded, uploadsOnly.received()[0].Type)
+
+	resp, _ = apiRequest(t, ts, http.MethodDelete, "/admin/webhooks/"+sub.ID, nil, nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = apiRequest(t, ts, http.MethodDelete, "/admin/webhooks/"+sub.ID, nil, nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestS3Gateway_Webhooks emits the bucket and file events of the REST API
+// from the S3 gateway.
+func TestS3Gateway_Webhooks(t *testing.T) {
+	subs, err := webhook.OpenStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	require.NoError(t, err)
+	rcv := newWebhookReceiver(t, "s3-secret", nil)
+	_, err = subs.Create(webhook.Subscription{URL: rcv.URL, Secret: "s3-secret"})
+	require.NoError(t, err)
+	d := webhook.NewDispatcher(webhook.Config{Subscriptions: subs})
+	runDispatcher(t, d)
+	gw := httptest.NewServer(s3.New(akavesdk.NewMemoryStorage(), s3.NewAuthenticator("us-east-1", awsExampleCreds), s3.Options{Webhooks: d}))
+	t.Cleanup(gw.Close)
+
+	steps := []struct{ method, path, body string }{
+		{http.MethodPut, "/hooks", ""},
+		{http.MethodPut, "/hooks/a.txt", "hello"},
+		{http.MethodDelete, "/hooks/a.txt", ""},
+		{http.MethodDelete, "/hooks/a.txt", ""},
+		{http.MethodDelete, "/hooks", ""},
+	}
+	for _, step := range steps {
+		resp, body := s3Request(t, gw, step.method, step.path, strings.NewReader(step.body), nil)
+		require.Less(t, resp.StatusCode, 300, "%s %s: %s", step.method, step.path, body)
+	}
+	resp, _ := s3Request(t, gw, http.MethodPut, "/hooks/missing-bucket.txt", strings.NewReader("hello"), nil)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = s3Request(t, gw, http.MethodPut, "/other", nil, nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = s3Request(t, gw, http.MethodPut, "/other/bad.txt", strings.NewReader("hello"),
+		map[string]string{checksum.SHA256Header: strings.Repeat("00", 32)})
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	want := []webhook.EventType{
+		webhook.BucketCreated, webhook.FileUploaded, webhook.FileDeleted, webhook.BucketDeleted,
+		webhook.FileFailed, webhook.BucketCreated, webhook.FileFailed,
+	}
+	require.Eventually(t, func() bool { return len(rcv.received()) == len(want) }, 5*time.Second, 5*time.Millisecond)
+	var types []webhook.EventType
+	var uploaded *webhook.Event
+	var codes []string
+	for _, ev := range rcv.received() {
+		ev := ev
+		types = append(types, ev.Type)
+		switch ev.Type {
+		case webhook.FileUploaded:
+			uploaded = &ev
+		case webhook.FileFailed:
+			codes = append(codes, ev.Code)
+		}
+		assert.Empty(t, ev.Tenant)
+	}
+	assert.ElementsMatch(t, want, types, "deleting a missing key is not reported")
+	require.NotNil(t, uploaded)
+	require.NotNil(t, uploaded.FileMeta)
+	assert.Equal(t, "a.txt", uploaded.File)
+	assert.NotEmpty(t, uploaded.FileMeta.RootCID)
+	assert.NotEmpty(t, uploaded.FileMeta.SHA256)
+	assert.ElementsMatch(t, []string{"NoSuchBucket", "BadDigest"}, codes)
+}
+
+// TestServer_TenantWebhooks delivers events only to subscriptions of the
+// tenant they happened in, and confines admin keys of a tenant to that
+// tenant's subscriptions.
+func TestServer_TenantWebhooks(t *testing.T) {
+	keys, err := auth.OpenStore(filepath.Join(t.TempDir(), "keys.json"))
+	require.NoError(t, err)
+	_, admin, err := keys.Create(auth.Key{Name: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	require.NoError(t, err)
+	_, adminA, err := keys.Create(auth.Key{Name: "a", Scopes: []auth.Scope{auth.ScopeAdmin}, Tenant: "team-a"})
+	require.NoError(t, err)
+	_, adminB, err := keys.Create(auth.Key{Name: "b", Scopes: []auth.Scope{auth.ScopeAdmin}, Tenant: "team-b"})
+	require.NoError(t, err)
+
+	subs, err := webhook.OpenStore(filepath.Join(t.TempDir(), "webhooks.json"))
+	require.NoError(t, err)
+	d := webhook.NewDispatcher(webhook.Config{Subscriptions: subs})
+	runDispatcher(t, d)
+	pool := tenant.NewPool(newTestRegistry(t), (&countingFactory{}).open, 0)
+	defer pool.Close()
+	srv, err := server.New(akavesdk.NewMemoryStorage(), server.Options{Keys: keys, Tenants: pool, Webhooks: d})
+	require.NoError(t, err)
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	bearer := func(token string) map[string]string {
+		return map[string]string{"Authorization": "Bearer " + token}
+	}
+
+	subscribe := func(token string, rcv *webhookReceiver, tenantID string) (int, webhook.Subscription) {
+		t.Helper()
+		resp, body := apiRequest(t, ts, http.MethodPost, "/admin/webhooks",
+			strings.NewReader(`{"url":"`+rcv.URL+`","secret":"`+rcv.secret+`","tenant":"`+tenantID+`"}`), bearer(token))
+		var sub webhook.Subscription
+		if resp.StatusCode == http.StatusCreated {
+			decodeAPI(t, body, &sub)
+		}
+		return resp.StatusCode, sub
+	}
+	rcvA := newWebhookReceiver(t, "secret-a", nil)
+	rcvB := newWebhookReceiver(t, "secret-b", nil)
+	rcvDefault := newWebhookReceiver(t, "secret-default", nil)
+
+	status, _ := subscribe(adminA, rcvA, "team-b")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, subA := subscribe(adminA, rcvA, "")
+	require.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, "team-a", subA.Tenant)
+	status, subB := subscribe(admin, rcvB, "team-b")
+	require.Equal(t, http.StatusCreated, status)
+	status, _ = subscribe(admin, rcvDefault, "")
+	require.Equal(t, http.StatusCreated, status)
+
+	resp, body := apiRequest(t, ts, http.MethodPut, "/buckets/hooks/files/a.txt", strings.NewReader("hello"), bearer(adminA))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+	resp, body = apiRequest(t, ts, http.MethodPost, "/buckets/only-b", nil, bearer(adminB))
+	require.Equal(t, http.StatusCreated, resp.StatusCode, string(body))
+
+	require.Eventually(t, func() bool { return len(rcvA.received()) == 2 && len(rcvB.received()) == 1 }, 5*time.Second, 5*time.Millisecond)
+	for _, ev := range rcvA.received() {
+		assert.Equal(t, "team-a", ev.Tenant)
+	}
+	assert.Equal(t, "only-b", rcvB.received()[0].Bucket)
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, rcvDefault.received(), "events of tenants are not sent to the default wallet's subscriptions")
+	assert.Len(t, rcvA.received(), 2)
+
+	resp, body = apiRequest(t, ts, http.MethodGet, "/admin/webhooks", nil, bearer(adminA))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var list []webhook.Subscription
+	decodeAPI(t, body, &list)
+	require.Len(t, list, 1)
+	assert.Equal(t, subA.ID, list[0].ID)
+
+	resp, _ = apiRequest(t, ts, http.MethodDelete, "/admin/webhooks/"+subB.ID, nil, bearer(adminA))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, body = apiRequest(t, ts, http.MethodGet, "/admin/webhooks", nil, bearer(admin))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	decodeAPI(t, body, &list)
+	assert.Len(t, list, 3, "admin keys without a tenant manage every subscription")
+}